@@ -8,9 +8,12 @@ import (
 	"github.com/Kapeland/task-Avito/internal/services"
 	"github.com/Kapeland/task-Avito/internal/storage"
 	"github.com/Kapeland/task-Avito/internal/storage/repository/postgresql/auth"
+	"github.com/Kapeland/task-Avito/internal/storage/repository/postgresql/catalog"
 	"github.com/Kapeland/task-Avito/internal/storage/repository/postgresql/users"
 	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/Kapeland/task-Avito/internal/utils/keyset"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/Kapeland/task-Avito/internal/utils/passhash"
 	"github.com/pressly/goose/v3"
 )
 
@@ -34,16 +37,38 @@ func Start(cfg *config.Config, lgr *logger.Logger) error {
 		}
 	}
 
-	usersRepo := users.New(dbStor.DB)
+	keys, err := keyset.Load(cfg.Auth.JWT)
+	if err != nil {
+		lgr.Error(err.Error(), "App", "Start", "keyset.Load")
+		return err
+	}
+
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		lgr.Error(err.Error(), "App", "Start", "passhash.New")
+		return err
+	}
+
+	catalogRepo := catalog.New(dbStor.DB)
+	usersRepo := users.New(dbStor.DB, catalogRepo, hasher)
 	authRepo := auth.New(dbStor.DB)
 
 	authStorage := storage.NewAuthStorage(authRepo)
 	usersStorage := storage.NewUsersStorage(usersRepo)
+	catalogStorage := storage.NewCatalogStorage(catalogRepo)
+
+	var authStorager models.AuthStorager = &authStorage
+	var sessionCache *storage.CachedAuthStorage
+	if cfg.Auth.SessionCache.Size > 0 {
+		sessionCache = storage.NewCachedAuthStorage(&authStorage, cfg.Auth.SessionCache)
+		authStorager = sessionCache
+	}
 
-	umdl := models.NewModelUsers(&usersStorage)
-	amdl := models.NewModelAuth(&authStorage, &usersStorage)
+	umdl := models.NewModelUsers(&usersStorage, cfg.Coins)
+	amdl := models.NewModelAuth(authStorager, &usersStorage, cfg.Auth, keys)
+	cmdl := models.NewModelCatalog(&catalogStorage)
 
-	serv := services.NewService(&umdl, &amdl)
+	serv := services.NewService(&umdl, &amdl, &cmdl, sessionCache)
 
 	return serv.Launch(cfg, lgr)
 }
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+)
+
+// DeactivateUser blocks login, incoming transfers and API keys of user and ends all his sessions.
+// Coins and items are kept until ActivateUser.
+// Returns ErrUserNotFound or err
+func (m *ModelAuth) DeactivateUser(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	err := m.us.SetUserStatusST(ctx, login, UserStatusDeactivated)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "DeactivateUser", "SetUserStatusST")
+
+		return err
+	}
+
+	return m.LogoutAll(ctx, login)
+}
+
+// ActivateUser returns deactivated user back
+// Returns ErrUserNotFound or err
+func (m *ModelAuth) ActivateUser(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	err := m.us.SetUserStatusST(ctx, login, UserStatusActive)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "ActivateUser", "SetUserStatusST")
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUser deletes user. By default user is anonymized: login is replaced with random one
+// and credentials are dropped, so history of other users stays consistent.
+// hard removes user with wallet and items, his operations stay in history without login.
+// Returns ErrUserNotFound or err
+func (m *ModelAuth) DeleteUser(ctx context.Context, login string, hard bool) error {
+	lgr := logger.GetLogger()
+
+	// Сначала завершаем сессии через auth storage, чтобы сбросить кэш секретов
+	if err := m.LogoutAll(ctx, login); err != nil {
+		return err
+	}
+
+	if hard {
+		err := m.us.DeleteUserST(ctx, login)
+		if err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return ErrUserNotFound
+			}
+			lgr.Error(err.Error(), "ModelAuth", "DeleteUser", "DeleteUserST")
+
+			return err
+		}
+
+		// Сессия могла попасть в кэш между LogoutAll и удалением, сбрасываем его ещё раз
+		return m.LogoutAll(ctx, login)
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "DeleteUser", "NewV4")
+
+		return err
+	}
+
+	err = m.us.AnonymizeUserST(ctx, login, "deleted-"+id.String())
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "DeleteUser", "AnonymizeUserST")
+
+		return err
+	}
+
+	// Секреты удалены в транзакции анонимизации мимо кэша, сбрасываем его по старому логину
+	return m.LogoutAll(ctx, login)
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type callsAuthStorage struct {
+	AuthStorager
+	calls *[]string
+}
+
+func (s callsAuthStorage) DeleteUserSecretsByLogin(_ context.Context, login string) error {
+	*s.calls = append(*s.calls, "logout "+login)
+	return nil
+}
+
+type callsUsersStorage struct {
+	UsersStorager
+	calls *[]string
+}
+
+func (s callsUsersStorage) DeleteUserST(_ context.Context, login string) error {
+	*s.calls = append(*s.calls, "delete "+login)
+	return nil
+}
+
+func (s callsUsersStorage) AnonymizeUserST(_ context.Context, login string, _ string) error {
+	*s.calls = append(*s.calls, "anonymize "+login)
+	return nil
+}
+
+func TestModelAuth_DeleteUser_InvalidatesAfterDelete(t *testing.T) {
+	logger.CreateLogger(&config.Config{})
+
+	tests := []struct {
+		name string
+		hard bool
+		want []string
+	}{
+		{"anonymize", false, []string{"logout user1user1", "anonymize user1user1", "logout user1user1"}},
+		{"hard", true, []string{"logout user1user1", "delete user1user1", "logout user1user1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+			m := NewModelAuth(callsAuthStorage{calls: &calls}, callsUsersStorage{calls: &calls}, config.Auth{}, nil)
+
+			require.NoError(t, m.DeleteUser(context.Background(), "user1user1", tt.hard))
+			assert.Equal(t, tt.want, calls)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gofrs/uuid"
+)
+
+// Scopes limit what API key can do on behalf of its owner
+const (
+	ScopeInfoRead      = "info:read"
+	ScopeCoinsSend     = "coins:send"
+	ScopeShopBuy       = "shop:buy"
+	ScopeInvitesCreate = "invites:create"
+)
+
+// apiKeyPrefix makes leaked keys easy to find by secret scanners
+const apiKeyPrefix = "shk_"
+
+var knownScopes = []string{ScopeInfoRead, ScopeCoinsSend, ScopeShopBuy, ScopeInvitesCreate}
+
+// CreateAPIKey issues API key of user. Key itself is returned only here, just its hash is stored.
+// Zero ttl means key doesn't expire.
+// Returns ErrUnknownScope or err
+func (m *ModelAuth) CreateAPIKey(ctx context.Context, login string, name string, scopes []string, ttl time.Duration) (structs.APIKey, error) {
+	lgr := logger.GetLogger()
+
+	if len(scopes) == 0 {
+		return structs.APIKey{}, ErrUnknownScope
+	}
+	uniqScopes := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return structs.APIKey{}, ErrUnknownScope
+		}
+		if !slices.Contains(uniqScopes, scope) {
+			uniqScopes = append(uniqScopes, scope)
+		}
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreateAPIKey", "NewV4")
+
+		return structs.APIKey{}, err
+	}
+
+	secret, err := genRefreshToken()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreateAPIKey", "genRefreshToken")
+
+		return structs.APIKey{}, err
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey := structs.APIKey{
+		ID:      id.String(),
+		KeyHash: hashToken(key),
+		Login:   login,
+		Name:    name,
+		Scopes:  uniqScopes,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	apiKey, err = m.as.CreateAPIKey(ctx, apiKey)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreateAPIKey", "CreateAPIKey")
+
+		return structs.APIKey{}, err
+	}
+	apiKey.Key = key
+
+	return apiKey, nil
+}
+
+// ListAPIKeys returns active API keys of user
+func (m *ModelAuth) ListAPIKeys(ctx context.Context, login string) ([]structs.APIKey, error) {
+	lgr := logger.GetLogger()
+
+	apiKeys, err := m.as.GetAPIKeysByLogin(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ListAPIKeys", "GetAPIKeysByLogin")
+
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+// RevokeAPIKey revokes API key of user
+// Returns ErrNotFound or err
+func (m *ModelAuth) RevokeAPIKey(ctx context.Context, login string, id string) error {
+	lgr := logger.GetLogger()
+
+	err := m.as.RevokeAPIKey(ctx, login, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "RevokeAPIKey", "RevokeAPIKey")
+
+		return err
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey finds active API key and fills roles of its owner
+// Returns ErrInvalidToken or err
+func (m *ModelAuth) AuthenticateAPIKey(ctx context.Context, key string) (structs.APIKey, error) {
+	lgr := logger.GetLogger()
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return structs.APIKey{}, ErrInvalidToken
+	}
+
+	apiKey, err := m.as.GetAPIKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return structs.APIKey{}, ErrInvalidToken
+		}
+		lgr.Error(err.Error(), "ModelAuth", "AuthenticateAPIKey", "GetAPIKeyByHash")
+
+		return structs.APIKey{}, err
+	}
+
+	apiKey.Roles, err = m.getUserRoles(ctx, apiKey.Login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "AuthenticateAPIKey", "getUserRoles")
+
+		return structs.APIKey{}, err
+	}
+
+	if err := m.as.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		// Время последнего использования не критично, запрос можно обслужить
+		lgr.Error(err.Error(), "ModelAuth", "AuthenticateAPIKey", "TouchAPIKey")
+	}
+
+	return apiKey, nil
+}
@@ -9,85 +9,178 @@ import (
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
-	"github.com/gofrs/uuid"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+const defaultInviteTTL = 7 * 24 * time.Hour
+
 type AuthStorager interface {
 	GetUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) (structs.UserSecret, error)
 	CreateUserSecret(ctx context.Context, userSecret structs.UserSecret) error
+	CreateRefreshToken(ctx context.Context, token structs.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, newToken structs.RefreshToken) (structs.UserSecret, error)
+	DeleteUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) error
+	DeleteUserSecretsByLogin(ctx context.Context, login string) error
+	DeleteUserSecretsByLoginExcept(ctx context.Context, login string, sessionID string) error
+	GetSessionsByLogin(ctx context.Context, login string) ([]structs.Session, error)
+	TouchSession(ctx context.Context, lgnSsn structs.UserSecret) error
+	GetLockedUntil(ctx context.Context, key string) (time.Time, error)
+	RegisterFailedAttempt(ctx context.Context, key string, window time.Duration) (int, error)
+	LockAttempts(ctx context.Context, key string, until time.Time) error
+	ResetAttempts(ctx context.Context, key string) error
+	CreateAPIKey(ctx context.Context, apiKey structs.APIKey) (structs.APIKey, error)
+	GetAPIKeysByLogin(ctx context.Context, login string) ([]structs.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (structs.APIKey, error)
+	RevokeAPIKey(ctx context.Context, login string, id string) error
+	TouchAPIKey(ctx context.Context, id string) error
+	UpsertPendingTOTP(ctx context.Context, login string, secret string) error
+	GetTOTP(ctx context.Context, login string) (structs.TOTP, error)
+	ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+	DeleteTOTP(ctx context.Context, login string) error
 }
 
-const validHoursNum = 24
-
-// RegisterUser registers/auth user + always new JWT
-func (m *ModelAuth) RegisterUser(ctx context.Context, info structs.RegisterUserInfo) (string, error) {
+// RegisterUser registers/auth user + always new session.
+// Legacy combined flow: unknown login creates account, known login is checked like in LogIn.
+// If invites are required, only LogIn part is left.
+func (m *ModelAuth) RegisterUser(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error) {
 	lgr := logger.GetLogger()
 
-	err := m.us.CreateUserST(ctx, info)
+	if m.cfg.RequireInvite {
+		return m.LogIn(ctx, info)
+	}
+
+	err := m.us.CreateUserST(ctx, info, "")
 	if err != nil {
 		if errors.Is(err, ErrUserConflict) {
 			// Пользователь с таким логином уже есть, значит сверим пароль
-			isPassCorrect, err := m.us.CheckPasswordST(ctx, structs.AuthUserInfo{Login: info.Login, Pswd: info.Pswd})
-			if err != nil {
-				lgr.Error(err.Error(), "ModelAuth", "LoginUser", "CheckPasswordST")
-
-				return "", err
-			}
-			if !isPassCorrect { // Это значит кто-то вводит существующий логин, но другой пароль
-				return "", ErrBadCredentials
-			}
-		} else {
-			lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "CreateUserDB")
-
-			return "", err
+			return m.LogIn(ctx, info)
 		}
+		lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "CreateUserDB")
+
+		return structs.AuthTokens{}, err
 	}
 
-	key, err := genKey(64)
-	if err != nil {
-		lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "genKey")
+	return m.newSession(ctx, info, "RegisterUser")
+}
+
+// SignUp creates account and its first session. Invite code is checked and spent if given or required.
+// Returns ErrUserConflict, ErrInviteRequired, ErrInvalidInvite or err
+func (m *ModelAuth) SignUp(ctx context.Context, info structs.RegisterUserInfo, inviteCode string) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
 
-		return "", err
+	if m.cfg.RequireInvite && inviteCode == "" {
+		return structs.AuthTokens{}, ErrInviteRequired
 	}
 
-	sessionID, err := uuid.NewV4()
+	inviteHash := ""
+	if inviteCode != "" {
+		inviteHash = hashToken(inviteCode)
+	}
+
+	err := m.us.CreateUserST(ctx, info, inviteHash)
 	if err != nil {
-		lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "NewV4")
+		if errors.Is(err, ErrUserConflict) {
+			return structs.AuthTokens{}, ErrUserConflict
+		}
+		if errors.Is(err, ErrInvalidInvite) {
+			return structs.AuthTokens{}, ErrInvalidInvite
+		}
+		lgr.Error(err.Error(), "ModelAuth", "SignUp", "CreateUserST")
 
-		return "", err
+		return structs.AuthTokens{}, err
 	}
 
-	payload := jwt.MapClaims{
-		"sub": info.Login,
-		"sID": sessionID.String(),
-		"exp": time.Now().Add(time.Hour * validHoursNum).Unix(),
+	return m.newSession(ctx, info, "SignUp")
+}
+
+// LogIn checks credentials of existing user and creates new session.
+// Unknown login and wrong password are indistinguishable. Users with TOTP also need one-time code.
+// Returns ErrBadCredentials, ErrOTPRequired, ErrInvalidOTP, *LockoutError or err
+func (m *ModelAuth) LogIn(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
+
+	if err := m.checkLockout(ctx, info); err != nil {
+		return structs.AuthTokens{}, err
 	}
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	tokStr, err := jwtToken.SignedString([]byte(key))
+
+	isPassCorrect, err := m.us.CheckPasswordST(ctx, structs.AuthUserInfo{Login: info.Login, Pswd: info.Pswd})
 	if err != nil {
-		lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "SignedString")
+		lgr.Error(err.Error(), "ModelAuth", "LogIn", "CheckPasswordST")
 
-		return "", err
+		return structs.AuthTokens{}, err
 	}
+	if !isPassCorrect {
+		m.registerFailure(ctx, info)
 
-	userSecret := structs.UserSecret{
-		Login:     info.Login,
-		Secret:    key,
-		SessionID: sessionID.String(),
+		return structs.AuthTokens{}, ErrBadCredentials
 	}
-	err = m.as.CreateUserSecret(ctx, userSecret)
+
+	err = m.checkSecondFactor(ctx, info)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOTP) {
+			m.registerFailure(ctx, info)
+
+			return structs.AuthTokens{}, ErrInvalidOTP
+		}
+		if errors.Is(err, ErrOTPRequired) {
+			return structs.AuthTokens{}, ErrOTPRequired
+		}
+		lgr.Error(err.Error(), "ModelAuth", "LogIn", "checkSecondFactor")
+
+		return structs.AuthTokens{}, err
+	}
+	m.resetFailures(ctx, info.Login)
+
+	return m.newSession(ctx, info, "LogIn")
+}
+
+func (m *ModelAuth) newSession(ctx context.Context, info structs.RegisterUserInfo, method string) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
+
+	tokens, err := m.createSession(ctx, info)
 	if err != nil {
 		if errors.Is(err, ErrConflict) {
-			// По идее такая ситуация может быть, когда секрет одинаковы, но шанс такого очень мал.
-			return "", ErrConflict
+			return structs.AuthTokens{}, ErrConflict
 		}
-		lgr.Error(err.Error(), "ModelAuth", "RegisterUser", "CreateUserSecret")
+		lgr.Error(err.Error(), "ModelAuth", method, "createSession")
+
+		return structs.AuthTokens{}, err
+	}
+
+	return tokens, nil
+}
+
+// CreateInvite issues single-use invite code for SignUp
+func (m *ModelAuth) CreateInvite(ctx context.Context, createdBy string) (structs.Invite, error) {
+	lgr := logger.GetLogger()
+
+	code, err := genRefreshToken()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreateInvite", "genRefreshToken")
 
-		return "", err
+		return structs.Invite{}, err
 	}
 
-	return tokStr, nil
+	ttl := m.cfg.InviteTTL
+	if ttl <= 0 {
+		ttl = defaultInviteTTL
+	}
+
+	invite := structs.Invite{
+		Code:      code,
+		CodeHash:  hashToken(code),
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err = m.us.CreateInviteST(ctx, invite)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreateInvite", "CreateInviteST")
+
+		return structs.Invite{}, err
+	}
+
+	return invite, nil
 }
 
 // GetUserSecretByLoginAndSession get user secret by given login and sessionID
@@ -98,6 +191,66 @@ func (m *ModelAuth) GetUserSecretByLoginAndSession(ctx context.Context, lgnSsn s
 	return userSecret, err
 }
 
+// Logout revokes the given session of user
+// Returns ErrNotFound or err
+func (m *ModelAuth) Logout(ctx context.Context, login string, sessionID string) error {
+	lgr := logger.GetLogger()
+
+	err := m.as.DeleteUserSecretByLoginAndSession(ctx, structs.UserSecret{Login: login, SessionID: sessionID})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "Logout", "DeleteUserSecretByLoginAndSession")
+
+		return err
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every session of user
+func (m *ModelAuth) LogoutAll(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	err := m.as.DeleteUserSecretsByLogin(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "LogoutAll", "DeleteUserSecretsByLogin")
+
+		return err
+	}
+
+	return nil
+}
+
+// ListSessions returns every session of user
+func (m *ModelAuth) ListSessions(ctx context.Context, login string) ([]structs.Session, error) {
+	lgr := logger.GetLogger()
+
+	sessions, err := m.as.GetSessionsByLogin(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ListSessions", "GetSessionsByLogin")
+
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession marks session as just used
+func (m *ModelAuth) TouchSession(ctx context.Context, login string, sessionID string) error {
+	lgr := logger.GetLogger()
+
+	err := m.as.TouchSession(ctx, structs.UserSecret{Login: login, SessionID: sessionID})
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "TouchSession", "TouchSession")
+
+		return err
+	}
+
+	return nil
+}
+
 func genKey(length int) (string, error) {
 	result := ""
 	for {
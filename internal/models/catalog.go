@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+type CatalogStorager interface {
+	ListItemsST(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error)
+	CreateItemST(ctx context.Context, change structs.ItemChange) (structs.Item, error)
+	UpdateItemST(ctx context.Context, change structs.ItemChange) (structs.Item, error)
+}
+
+func (m *ModelCatalog) ListItems(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	items, err := m.cs.ListItemsST(ctx, filter)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelCatalog", "ListItems", "ListItemsST")
+
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// CreateItem adds new item to catalog
+// Returns ErrConflict or err
+func (m *ModelCatalog) CreateItem(ctx context.Context, item structs.Item, changedBy string) (structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	created, err := m.cs.CreateItemST(ctx, structs.ItemChange{
+		Name:      item.Name,
+		Price:     &item.Price,
+		Available: &item.Available,
+		Action:    structs.ItemActionCreate,
+		ChangedBy: changedBy,
+	})
+	if err != nil {
+		if errors.Is(err, ErrConflict) {
+			return structs.Item{}, ErrConflict
+		}
+		lgr.Error(err.Error(), "ModelCatalog", "CreateItem", "CreateItemST")
+
+		return structs.Item{}, err
+	}
+
+	return created, nil
+}
+
+// UpdateItem changes price and/or availability of item
+// Returns ErrNoSuchItem or err
+func (m *ModelCatalog) UpdateItem(ctx context.Context, change structs.ItemChange) (structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	if change.Action == "" {
+		change.Action = structs.ItemActionUpdate
+	}
+
+	updated, err := m.cs.UpdateItemST(ctx, change)
+	if err != nil {
+		if errors.Is(err, ErrNoSuchItem) {
+			return structs.Item{}, ErrNoSuchItem
+		}
+		lgr.Error(err.Error(), "ModelCatalog", "UpdateItem", "UpdateItemST")
+
+		return structs.Item{}, err
+	}
+
+	return updated, nil
+}
+
+// RepriceItem sets new price of item
+// Returns ErrNoSuchItem or err
+func (m *ModelCatalog) RepriceItem(ctx context.Context, name string, price int, changedBy string) (structs.Item, error) {
+	return m.UpdateItem(ctx, structs.ItemChange{
+		Name:      name,
+		Price:     &price,
+		Action:    structs.ItemActionReprice,
+		ChangedBy: changedBy,
+	})
+}
+
+// RetireItem makes item unavailable for purchase. Item stays in catalog and inventories.
+// Returns ErrNoSuchItem or err
+func (m *ModelCatalog) RetireItem(ctx context.Context, name string, changedBy string) (structs.Item, error) {
+	available := false
+
+	return m.UpdateItem(ctx, structs.ItemChange{
+		Name:      name,
+		Available: &available,
+		Action:    structs.ItemActionRetire,
+		ChangedBy: changedBy,
+	})
+}
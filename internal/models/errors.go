@@ -17,3 +17,55 @@ var ErrNoSuchItem = errors.New("unknown item")
 var ErrUserNotFound = errors.New("user not found")
 
 var ErrInsufficientBalance = errors.New("insufficient balance")
+
+var ErrUnknownRole = errors.New("unknown role")
+
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+
+var ErrInviteRequired = errors.New("invite code required")
+
+var ErrInvalidInvite = errors.New("invalid or expired invite code")
+
+var ErrTooManyAttempts = errors.New("too many failed attempts, try later")
+
+var ErrUnknownScope = errors.New("unknown scope")
+
+var ErrOTPRequired = errors.New("one-time code required")
+
+var ErrInvalidOTP = errors.New("invalid one-time code")
+
+var ErrTOTPEnabled = errors.New("two-factor authentication already enabled")
+
+var ErrTOTPNotEnabled = errors.New("two-factor authentication not enabled")
+
+var ErrUserDeactivated = errors.New("user is deactivated")
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used with another request")
+
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+var ErrNonPositiveAmount = errors.New("amount must be positive")
+
+var ErrSelfTransfer = errors.New("can't send coins to yourself")
+
+var ErrAmountAboveMax = errors.New("amount is above maximum per transfer")
+
+var ErrDailyLimitExceeded = errors.New("daily transfer limit exceeded")
+
+var ErrWeeklyLimitExceeded = errors.New("weekly transfer limit exceeded")
+
+var ErrRecipientLimitExceeded = errors.New("daily transfer limit to this recipient exceeded")
+
+var ErrOperationNotFound = errors.New("operation not found")
+
+var ErrNotReversible = errors.New("operation can't be reversed")
+
+var ErrAlreadyReversed = errors.New("operation is already reversed")
+
+var ErrCoinsSpent = errors.New("recipient has already spent coins")
+
+var ErrUndoExpired = errors.New("undo window expired")
+
+var ErrUndoDisabled = errors.New("undo is disabled")
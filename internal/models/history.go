@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gofrs/uuid"
+)
+
+// History returns page of user's ledger entries, newest first.
+// cursor is NextCursor of previous page, empty for the first one.
+// Returns ErrInvalidCursor or err
+func (m *ModelUsers) History(ctx context.Context, filter structs.HistoryFilter, cursor string) (structs.HistoryPage, error) {
+	lgr := logger.GetLogger()
+
+	if cursor != "" {
+		after, err := decodeHistoryCursor(cursor)
+		if err != nil {
+			return structs.HistoryPage{}, ErrInvalidCursor
+		}
+		filter.After = &after
+	}
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := m.us.GetHistoryST(ctx, filter)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelUsers", "History", "GetHistoryST")
+
+		return structs.HistoryPage{}, err
+	}
+
+	page := structs.HistoryPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		last := page.Entries[limit-1]
+		page.NextCursor = encodeHistoryCursor(structs.HistoryCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// Курсор непрозрачен для клиента: base64 от "created_at|id"
+func encodeHistoryCursor(c structs.HistoryCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+func decodeHistoryCursor(s string) (structs.HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return structs.HistoryCursor{}, err
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return structs.HistoryCursor{}, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return structs.HistoryCursor{}, err
+	}
+	if _, err := uuid.FromString(id); err != nil {
+		return structs.HistoryCursor{}, err
+	}
+
+	return structs.HistoryCursor{CreatedAt: t, ID: id}, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryCursor(t *testing.T) {
+	c := structs.HistoryCursor{
+		CreatedAt: time.Date(2025, 3, 2, 10, 15, 47, 123456000, time.UTC),
+		ID:        "3f0c6a8e-2a4b-4c47-9a53-7c1f0e5b2d11",
+	}
+
+	got, err := decodeHistoryCursor(encodeHistoryCursor(c))
+	assert.NoError(t, err)
+	assert.True(t, c.CreatedAt.Equal(got.CreatedAt))
+	assert.Equal(t, c.ID, got.ID)
+
+	for _, bad := range []string{"", "!!!", "MjAyNQ", encodeHistoryCursor(structs.HistoryCursor{ID: "not-uuid"})} {
+		_, err := decodeHistoryCursor(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+const (
+	defaultIdempotencyRetention = 24 * time.Hour
+	defaultIdempotencyLease     = time.Minute
+)
+
+// StartIdempotent registers request with Idempotency-Key.
+// Returns true if request is new or its previous attempt left unfinished key with expired lease, and must be executed.
+// Otherwise returns saved result to replay. Result of committed operation may have no status code,
+// if server went down before saving it.
+// Returns ErrIdempotencyKeyReused, ErrIdempotencyInProgress or err
+func (m *ModelUsers) StartIdempotent(ctx context.Context, login string, key string, requestHash string) (structs.IdempotencyRecord, bool, error) {
+	lgr := logger.GetLogger()
+
+	retention := m.cfg.Idempotency.Retention
+	if retention <= 0 {
+		retention = defaultIdempotencyRetention
+	}
+	lease := m.cfg.Idempotency.Lease
+	if lease <= 0 {
+		lease = defaultIdempotencyLease
+	}
+
+	rec, created, err := m.us.CreateIdempotencyKeyST(ctx, structs.IdempotencyRecord{
+		Login:       login,
+		Key:         key,
+		RequestHash: requestHash,
+	}, retention, lease)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelUsers", "StartIdempotent", "CreateIdempotencyKeyST")
+
+		return structs.IdempotencyRecord{}, false, err
+	}
+	if created {
+		return rec, true, nil
+	}
+
+	if rec.RequestHash != requestHash {
+		return structs.IdempotencyRecord{}, false, ErrIdempotencyKeyReused
+	}
+	if rec.StatusCode == nil && rec.OperationID == nil {
+		return structs.IdempotencyRecord{}, false, ErrIdempotencyInProgress
+	}
+
+	return rec, false, nil
+}
+
+// FinishIdempotent saves result of request to replay it on retry
+func (m *ModelUsers) FinishIdempotent(ctx context.Context, login string, key string, statusCode int, response []byte) error {
+	lgr := logger.GetLogger()
+
+	err := m.us.SaveIdempotentResponseST(ctx, login, key, statusCode, response)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelUsers", "FinishIdempotent", "SaveIdempotentResponseST")
+
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseIdempotent forgets request which failed without effect, so it may be retried with the same key
+func (m *ModelUsers) ReleaseIdempotent(ctx context.Context, login string, key string) error {
+	lgr := logger.GetLogger()
+
+	err := m.us.DeleteIdempotencyKeyST(ctx, login, key)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelUsers", "ReleaseIdempotent", "DeleteIdempotencyKeyST")
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+// LockoutError is returned while login or client IP is locked after too many failed attempts
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+type attemptsKey struct {
+	key         string
+	maxFailures int
+}
+
+func (m *ModelAuth) attemptsKeys(info structs.RegisterUserInfo) []attemptsKey {
+	keys := []attemptsKey{{key: "login:" + info.Login, maxFailures: m.cfg.Lockout.LoginMaxFailures}}
+	if info.ClientIP != "" {
+		keys = append(keys, attemptsKey{key: "ip:" + info.ClientIP, maxFailures: m.cfg.Lockout.IPMaxFailures})
+	}
+
+	return keys
+}
+
+// checkLockout returns *LockoutError if login or client IP is locked now
+func (m *ModelAuth) checkLockout(ctx context.Context, info structs.RegisterUserInfo) error {
+	lgr := logger.GetLogger()
+
+	var retryAfter time.Duration
+	for _, k := range m.attemptsKeys(info) {
+		if k.maxFailures <= 0 {
+			continue
+		}
+
+		lockedUntil, err := m.as.GetLockedUntil(ctx, k.key)
+		if err != nil {
+			lgr.Error(err.Error(), "ModelAuth", "checkLockout", "GetLockedUntil")
+
+			return err
+		}
+		if d := time.Until(lockedUntil); d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// registerFailure counts failed attempt and locks keys that reached their threshold
+func (m *ModelAuth) registerFailure(ctx context.Context, info structs.RegisterUserInfo) {
+	lgr := logger.GetLogger()
+
+	for _, k := range m.attemptsKeys(info) {
+		if k.maxFailures <= 0 {
+			continue
+		}
+
+		failures, err := m.as.RegisterFailedAttempt(ctx, k.key, m.cfg.Lockout.FailureWindow)
+		if err != nil {
+			lgr.Error(err.Error(), "ModelAuth", "registerFailure", "RegisterFailedAttempt")
+
+			continue
+		}
+
+		delay := lockoutDelay(failures, k.maxFailures, m.cfg.Lockout.BaseDelay, m.cfg.Lockout.MaxDelay)
+		if delay <= 0 {
+			continue
+		}
+		if err := m.as.LockAttempts(ctx, k.key, time.Now().Add(delay)); err != nil {
+			lgr.Error(err.Error(), "ModelAuth", "registerFailure", "LockAttempts")
+		}
+	}
+}
+
+// resetFailures forgets failed attempts of login. Client IP counter is kept,
+// otherwise one valid account would be enough to keep guessing from the same IP.
+func (m *ModelAuth) resetFailures(ctx context.Context, login string) {
+	lgr := logger.GetLogger()
+
+	if m.cfg.Lockout.LoginMaxFailures <= 0 {
+		return
+	}
+	if err := m.as.ResetAttempts(ctx, "login:"+login); err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "resetFailures", "ResetAttempts")
+	}
+}
+
+// lockoutDelay returns lock duration after the failures-th failed attempt.
+// Lock starts at maxFailures with base delay and doubles with every next failure up to maxDelay.
+// Without maxDelay it stops doubling before time.Duration overflows.
+func lockoutDelay(failures, maxFailures int, base, maxDelay time.Duration) time.Duration {
+	if maxFailures <= 0 || failures < maxFailures || base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := maxFailures; i < failures; i++ {
+		if delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_lockoutDelay(t *testing.T) {
+	type args struct {
+		failures    int
+		maxFailures int
+		base        time.Duration
+		maxDelay    time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+	}{
+		{
+			name: "Below threshold",
+			args: args{failures: 4, maxFailures: 5, base: 30 * time.Second, maxDelay: 15 * time.Minute},
+			want: 0,
+		},
+		{
+			name: "Threshold reached",
+			args: args{failures: 5, maxFailures: 5, base: 30 * time.Second, maxDelay: 15 * time.Minute},
+			want: 30 * time.Second,
+		},
+		{
+			name: "Doubles after threshold",
+			args: args{failures: 7, maxFailures: 5, base: 30 * time.Second, maxDelay: 15 * time.Minute},
+			want: 2 * time.Minute,
+		},
+		{
+			name: "Capped by max delay",
+			args: args{failures: 100, maxFailures: 5, base: 30 * time.Second, maxDelay: 15 * time.Minute},
+			want: 15 * time.Minute,
+		},
+		{
+			name: "No max delay, many failures",
+			args: args{failures: 1000, maxFailures: 5, base: time.Second, maxDelay: 0},
+			want: time.Second << 33,
+		},
+		{
+			name: "No max delay, doubles",
+			args: args{failures: 8, maxFailures: 5, base: time.Second, maxDelay: 0},
+			want: 8 * time.Second,
+		},
+		{
+			name: "Lockout disabled",
+			args: args{failures: 100, maxFailures: 0, base: 30 * time.Second, maxDelay: 15 * time.Minute},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockoutDelay(tt.args.failures, tt.args.maxFailures, tt.args.base, tt.args.maxDelay); got != tt.want {
+				t.Errorf("lockoutDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
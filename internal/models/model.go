@@ -2,33 +2,91 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/Kapeland/task-Avito/internal/utils/keyset"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type ModelUsers struct {
-	us UsersStorager
+	us  UsersStorager
+	cfg config.Coins
 }
 
 type ModelAuth struct {
-	as AuthStorager
-	us UsersStorager
+	as   AuthStorager
+	us   UsersStorager
+	cfg  config.Auth
+	keys *keyset.KeySet
 }
 
-func NewModelUsers(us UsersStorager) ModelUsers {
-	return ModelUsers{us}
+type ModelCatalog struct {
+	cs CatalogStorager
 }
-func NewModelAuth(as AuthStorager, us UsersStorager) ModelAuth {
-	return ModelAuth{as, us}
+
+func NewModelUsers(us UsersStorager, cfg config.Coins) ModelUsers {
+	return ModelUsers{us, cfg}
+}
+
+// NewModelAuth creates auth model. Nil keys means tokens are signed with session secrets (HMAC mode).
+func NewModelAuth(as AuthStorager, us UsersStorager, cfg config.Auth, keys *keyset.KeySet) ModelAuth {
+	return ModelAuth{as, us, cfg, keys}
+}
+func NewModelCatalog(cs CatalogStorager) ModelCatalog {
+	return ModelCatalog{cs}
 }
 
 type AuthModelManager interface {
-	RegisterUser(ctx context.Context, info structs.RegisterUserInfo) (string, error)
+	RegisterUser(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error)
+	SignUp(ctx context.Context, info structs.RegisterUserInfo, inviteCode string) (structs.AuthTokens, error)
+	LogIn(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error)
+	CreateInvite(ctx context.Context, createdBy string) (structs.Invite, error)
+	Refresh(ctx context.Context, refreshToken string) (structs.AuthTokens, error)
 	GetUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) (structs.UserSecret, error)
+	AccessTokenKey(token *jwt.Token, userSecret structs.UserSecret) (interface{}, error)
+	JWKS() keyset.JWKS
+	Logout(ctx context.Context, login string, sessionID string) error
+	LogoutAll(ctx context.Context, login string) error
+	ListSessions(ctx context.Context, login string) ([]structs.Session, error)
+	TouchSession(ctx context.Context, login string, sessionID string) error
+	ChangePassword(ctx context.Context, login string, sessionID string, oldPswd string, newPswd string) error
+	CreatePasswordReset(ctx context.Context, login string, createdBy string) (structs.PasswordReset, error)
+	ResetPassword(ctx context.Context, token string, newPswd string) error
+	EnrollTOTP(ctx context.Context, login string) (structs.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, login string, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, login string, code string) error
+	CreateAPIKey(ctx context.Context, login string, name string, scopes []string, ttl time.Duration) (structs.APIKey, error)
+	ListAPIKeys(ctx context.Context, login string) ([]structs.APIKey, error)
+	RevokeAPIKey(ctx context.Context, login string, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (structs.APIKey, error)
+	DeactivateUser(ctx context.Context, login string) error
+	ActivateUser(ctx context.Context, login string) error
+	DeleteUser(ctx context.Context, login string, hard bool) error
+	GrantRole(ctx context.Context, login string, role string) error
+	RevokeRole(ctx context.Context, login string, role string) error
 }
 
 type UsersModelManager interface {
 	SendCoin(ctx context.Context, operation structs.SendCoinInfo) error
-	BuyItem(ctx context.Context, item string, login string) error
-	Info(ctx context.Context, login string) (structs.AccInfo, error)
+	BuyItem(ctx context.Context, item string, login string, idempotencyKey string) error
+	Info(ctx context.Context, login string, withNames bool) (structs.AccInfo, error)
+	Profile(ctx context.Context, login string) (structs.Profile, error)
+	UpdateProfile(ctx context.Context, change structs.ProfileChange) (structs.Profile, error)
+	SearchUsers(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, bool, error)
+	History(ctx context.Context, filter structs.HistoryFilter, cursor string) (structs.HistoryPage, error)
+	StartIdempotent(ctx context.Context, login string, key string, requestHash string) (structs.IdempotencyRecord, bool, error)
+	FinishIdempotent(ctx context.Context, login string, key string, statusCode int, response []byte) error
+	ReleaseIdempotent(ctx context.Context, login string, key string) error
+	ReverseOperation(ctx context.Context, operationID string, by string, reason string) ([]structs.LedgerEntry, error)
+	UndoTransfer(ctx context.Context, login string, operationID string) ([]structs.LedgerEntry, error)
+}
+
+type CatalogModelManager interface {
+	ListItems(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error)
+	CreateItem(ctx context.Context, item structs.Item, changedBy string) (structs.Item, error)
+	UpdateItem(ctx context.Context, change structs.ItemChange) (structs.Item, error)
+	RepriceItem(ctx context.Context, name string, price int, changedBy string) (structs.Item, error)
+	RetireItem(ctx context.Context, name string, changedBy string) (structs.Item, error)
 }
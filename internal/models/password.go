@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+const defaultPasswordResetTTL = 24 * time.Hour
+
+// ChangePassword sets new password if the old one is correct and revokes every other session of user.
+// Wrong old password counts as failed login attempt.
+// Returns ErrBadCredentials, *LockoutError or err
+func (m *ModelAuth) ChangePassword(ctx context.Context, login string, sessionID string, oldPswd string, newPswd string) error {
+	lgr := logger.GetLogger()
+
+	info := structs.RegisterUserInfo{Login: login, Pswd: oldPswd}
+
+	if err := m.checkLockout(ctx, info); err != nil {
+		return err
+	}
+
+	isPassCorrect, err := m.us.CheckPasswordST(ctx, structs.AuthUserInfo{Login: login, Pswd: oldPswd})
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ChangePassword", "CheckPasswordST")
+
+		return err
+	}
+	if !isPassCorrect {
+		m.registerFailure(ctx, info)
+
+		return ErrBadCredentials
+	}
+
+	err = m.us.UpdatePasswordST(ctx, structs.AuthUserInfo{Login: login, Pswd: newPswd})
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ChangePassword", "UpdatePasswordST")
+
+		return err
+	}
+
+	err = m.as.DeleteUserSecretsByLoginExcept(ctx, login, sessionID)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ChangePassword", "DeleteUserSecretsByLoginExcept")
+
+		return err
+	}
+
+	return nil
+}
+
+// CreatePasswordReset issues one-time token that lets user set new password without the old one
+// Returns ErrUserNotFound or err
+func (m *ModelAuth) CreatePasswordReset(ctx context.Context, login string, createdBy string) (structs.PasswordReset, error) {
+	lgr := logger.GetLogger()
+
+	token, err := genRefreshToken()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "CreatePasswordReset", "genRefreshToken")
+
+		return structs.PasswordReset{}, err
+	}
+
+	ttl := m.cfg.PasswordResetTTL
+	if ttl <= 0 {
+		ttl = defaultPasswordResetTTL
+	}
+
+	reset := structs.PasswordReset{
+		Token:     token,
+		TokenHash: hashToken(token),
+		Login:     login,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err = m.us.CreatePasswordResetST(ctx, reset)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return structs.PasswordReset{}, ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "CreatePasswordReset", "CreatePasswordResetST")
+
+		return structs.PasswordReset{}, err
+	}
+
+	return reset, nil
+}
+
+// ResetPassword spends reset token, sets new password and revokes every session of user
+// Returns ErrInvalidToken or err
+func (m *ModelAuth) ResetPassword(ctx context.Context, token string, newPswd string) error {
+	lgr := logger.GetLogger()
+
+	login, err := m.us.RedeemPasswordResetST(ctx, hashToken(token), newPswd)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return ErrInvalidToken
+		}
+		lgr.Error(err.Error(), "ModelAuth", "ResetPassword", "RedeemPasswordResetST")
+
+		return err
+	}
+
+	err = m.as.DeleteUserSecretsByLogin(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "ResetPassword", "DeleteUserSecretsByLogin")
+
+		return err
+	}
+	m.resetFailures(ctx, login)
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+// Profile returns profile of user
+// Returns ErrUserNotFound or err
+func (m *ModelUsers) Profile(ctx context.Context, login string) (structs.Profile, error) {
+	lgr := logger.GetLogger()
+
+	profile, err := m.us.GetProfileST(ctx, login)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return structs.Profile{}, ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelUsers", "Profile", "GetProfileST")
+
+		return structs.Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile changes profile of user and returns it
+// Returns ErrUserNotFound or err
+func (m *ModelUsers) UpdateProfile(ctx context.Context, change structs.ProfileChange) (structs.Profile, error) {
+	lgr := logger.GetLogger()
+
+	err := m.us.UpdateProfileST(ctx, change)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return structs.Profile{}, ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelUsers", "UpdateProfile", "UpdateProfileST")
+
+		return structs.Profile{}, err
+	}
+
+	return m.Profile(ctx, change.Login)
+}
+
+// SearchUsers finds active users for filter.Query. Second value reports if there are more results after this page.
+func (m *ModelUsers) SearchUsers(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, bool, error) {
+	lgr := logger.GetLogger()
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := m.us.SearchUsersST(ctx, filter)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelUsers", "SearchUsers", "SearchUsersST")
+
+		return nil, false, err
+	}
+
+	if len(entries) > limit {
+		return entries[:limit], true, nil
+	}
+
+	return entries, false, nil
+}
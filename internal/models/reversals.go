@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+// reversalErrors are returned to the caller as is
+var reversalErrors = []error{
+	ErrOperationNotFound, ErrNotReversible, ErrAlreadyReversed, ErrUndoExpired, ErrCoinsSpent, ErrInsufficientBalance,
+}
+
+// ReverseOperation posts compensating refund for transfer or purchase. Used by admins.
+// Returns ErrOperationNotFound, ErrNotReversible, ErrAlreadyReversed, ErrInsufficientBalance or err
+func (m *ModelUsers) ReverseOperation(ctx context.Context, operationID string, by string, reason string) ([]structs.LedgerEntry, error) {
+	return m.reverse(ctx, structs.Reversal{OperationID: operationID, By: by, Reason: reason}, "ReverseOperation")
+}
+
+// UndoTransfer lets sender reverse his transfer during undo window, if recipient has not spent coins yet.
+// Returns ErrUndoDisabled, ErrOperationNotFound, ErrAlreadyReversed, ErrUndoExpired, ErrCoinsSpent or err
+func (m *ModelUsers) UndoTransfer(ctx context.Context, login string, operationID string) ([]structs.LedgerEntry, error) {
+	if m.cfg.Transfers.UndoWindow <= 0 {
+		return nil, ErrUndoDisabled
+	}
+
+	return m.reverse(ctx, structs.Reversal{
+		OperationID: operationID,
+		By:          login,
+		Sender:      login,
+		Window:      m.cfg.Transfers.UndoWindow,
+	}, "UndoTransfer")
+}
+
+func (m *ModelUsers) reverse(ctx context.Context, reversal structs.Reversal, method string) ([]structs.LedgerEntry, error) {
+	lgr := logger.GetLogger()
+
+	entries, err := m.us.ReverseOperationST(ctx, reversal)
+	if err != nil {
+		for _, e := range reversalErrors {
+			if errors.Is(err, e) {
+				return nil, e
+			}
+		}
+		lgr.Error(err.Error(), "ModelUsers", method, "ReverseOperationST")
+
+		return nil, err
+	}
+
+	lgr.Info("operation "+reversal.OperationID+" reversed by "+reversal.By, "ModelUsers", method, "ReverseOperationST")
+
+	return entries, nil
+}
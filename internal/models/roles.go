@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+const (
+	RoleEmployee = "employee"
+	RoleAdmin    = "admin"
+	RoleHR       = "hr"
+	RoleSupport  = "support"
+)
+
+// grantableRoles can be stored in users_schema.user_roles. RoleEmployee is implicit for every user.
+var grantableRoles = []string{RoleAdmin, RoleHR, RoleSupport}
+
+// getUserRoles get roles granted to user, RoleEmployee included
+func (m *ModelAuth) getUserRoles(ctx context.Context, login string) ([]string, error) {
+	lgr := logger.GetLogger()
+
+	roles, err := m.us.GetRolesST(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "getUserRoles", "GetRolesST")
+
+		return nil, err
+	}
+
+	return append([]string{RoleEmployee}, roles...), nil
+}
+
+// GrantRole grants role to user. New role gets into JWT on the next login.
+// Returns ErrUnknownRole, ErrUserNotFound or err
+func (m *ModelAuth) GrantRole(ctx context.Context, login string, role string) error {
+	lgr := logger.GetLogger()
+
+	if !slices.Contains(grantableRoles, role) {
+		return ErrUnknownRole
+	}
+
+	err := m.us.GrantRoleST(ctx, login, role)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		lgr.Error(err.Error(), "ModelAuth", "GrantRole", "GrantRoleST")
+
+		return err
+	}
+
+	return nil
+}
+
+// RevokeRole revokes role from user. Already issued JWTs keep the role until they expire.
+// Returns ErrUnknownRole or err
+func (m *ModelAuth) RevokeRole(ctx context.Context, login string, role string) error {
+	lgr := logger.GetLogger()
+
+	if !slices.Contains(grantableRoles, role) {
+		return ErrUnknownRole
+	}
+
+	err := m.us.RevokeRoleST(ctx, login, role)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "RevokeRole", "RevokeRoleST")
+
+		return err
+	}
+
+	return nil
+}
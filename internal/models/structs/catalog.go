@@ -0,0 +1,35 @@
+package structs
+
+const (
+	ItemsSortByName      = ""
+	ItemsSortByPriceAsc  = "price_asc"
+	ItemsSortByPriceDesc = "price_desc"
+)
+
+type Item struct {
+	Name      string `json:"name" db:"name"`
+	Price     int    `json:"price" db:"price"`
+	Available bool   `json:"available" db:"available"`
+}
+
+type ItemsFilter struct {
+	MaxPrice *int   `json:"maxPrice"`
+	Sort     string `json:"sort"`
+}
+
+const (
+	ItemActionCreate  = "create"
+	ItemActionUpdate  = "update"
+	ItemActionReprice = "reprice"
+	ItemActionRetire  = "retire"
+)
+
+// ItemChange describes admin change of catalog item.
+// Nil Price or Available means "leave as is".
+type ItemChange struct {
+	Name      string
+	Price     *int
+	Available *bool
+	Action    string
+	ChangedBy string
+}
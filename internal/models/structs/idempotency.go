@@ -0,0 +1,13 @@
+package structs
+
+// IdempotencyRecord is request made with Idempotency-Key.
+// Nil StatusCode means request is still in progress, unless OperationID is set:
+// then its operation is committed and only response wasn't saved.
+type IdempotencyRecord struct {
+	Login       string  `db:"login"`
+	Key         string  `db:"key"`
+	RequestHash string  `db:"request_hash"`
+	StatusCode  *int    `db:"status_code"`
+	Response    []byte  `db:"response"`
+	OperationID *string `db:"operation_id"`
+}
@@ -0,0 +1,74 @@
+package structs
+
+import "time"
+
+const (
+	LedgerTypeTransfer = "transfer"
+	LedgerTypePurchase = "purchase"
+	LedgerTypeGrant    = "grant"
+	LedgerTypeRefund   = "refund"
+)
+
+// LedgerEntry is change of one account. Amount is negative for debit.
+// Transfer writes two entries with the same OperationID.
+type LedgerEntry struct {
+	ID           string    `json:"id" db:"id"`
+	OperationID  string    `json:"operationId" db:"operation_id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	Login        *string   `json:"login,omitempty" db:"login"`
+	Type         string    `json:"type" db:"type"`
+	Amount       int       `json:"amount" db:"amount"`
+	Counterparty *string   `json:"counterparty,omitempty" db:"counterparty"`
+	Item         *string   `json:"item,omitempty" db:"item"`
+	BalanceAfter int       `json:"balanceAfter" db:"balance_after"`
+	Message      *string   `json:"message,omitempty" db:"message"`
+	Tag          *string   `json:"tag,omitempty" db:"tag"`
+	Reverses     *string   `json:"reverses,omitempty" db:"reverses"`
+}
+
+const (
+	HistoryDirectionIn  = "in"
+	HistoryDirectionOut = "out"
+)
+
+// HistoryCursor points to the last entry of previous page
+type HistoryCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// HistoryFilter selects ledger entries of Login. Empty fields don't filter.
+// From is inclusive, To is exclusive.
+type HistoryFilter struct {
+	Login        string
+	Direction    string
+	Counterparty string
+	Type         string
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	After        *HistoryCursor
+}
+
+type HistoryPage struct {
+	Entries    []LedgerEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// TransferStats is sum of coins sent by user in rolling windows
+type TransferStats struct {
+	SentDay            int `db:"sent_day"`
+	SentWeek           int `db:"sent_week"`
+	SentToRecipientDay int `db:"sent_to_recipient_day"`
+}
+
+// Reversal describes compensation of ledger operation.
+// Non-empty Sender means undo by user: only his transfer younger than Window can be reversed,
+// and only if recipient's balance has not changed since.
+type Reversal struct {
+	OperationID string
+	By          string
+	Reason      string
+	Sender      string
+	Window      time.Duration
+}
@@ -0,0 +1,38 @@
+package structs
+
+import "time"
+
+// Profile is public info about user. Users without profile row have empty fields.
+type Profile struct {
+	Login       string     `json:"login" db:"login"`
+	DisplayName string     `json:"displayName" db:"display_name"`
+	Department  string     `json:"department" db:"department"`
+	AvatarURL   string     `json:"avatarUrl" db:"avatar_url"`
+	Bio         string     `json:"bio" db:"bio"`
+	Status      string     `json:"status" db:"status"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty" db:"updated_at"`
+}
+
+// ProfileChange describes change of user's own profile.
+// Nil field means "leave as is".
+type ProfileChange struct {
+	Login       string
+	DisplayName *string
+	Department  *string
+	AvatarURL   *string
+	Bio         *string
+}
+
+// DirectoryEntry is user found in directory search
+type DirectoryEntry struct {
+	Login       string `json:"login" db:"login"`
+	DisplayName string `json:"displayName" db:"display_name"`
+	Department  string `json:"department" db:"department"`
+	AvatarURL   string `json:"avatarUrl" db:"avatar_url"`
+}
+
+type UsersFilter struct {
+	Query  string
+	Limit  int
+	Offset int
+}
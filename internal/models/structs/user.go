@@ -1,14 +1,27 @@
 package structs
 
+import "time"
+
+// SendCoinInfo is transfer. Message and Tag are optional, empty means absent.
+// Non-empty IdempotencyKey is marked as done in the same transaction as transfer.
 type SendCoinInfo struct {
-	From   string `json:"from"`
-	To     string `json:"toUser"`
-	Amount int    `json:"amount"`
+	From           string `json:"from"`
+	To             string `json:"toUser"`
+	Amount         int    `json:"amount"`
+	Message        string `json:"message"`
+	Tag            string `json:"tag"`
+	IdempotencyKey string `json:"-"`
 }
 
+// DeletedUserName is shown in history instead of login of deleted user
+const DeletedUserName = "deleted user"
+
 type RegisterUserInfo struct {
-	Login string `json:"login"`
-	Pswd  string `json:"pswd"`
+	Login     string `json:"login"`
+	Pswd      string `json:"pswd"`
+	OTP       string `json:"otp"`
+	UserAgent string `json:"-"`
+	ClientIP  string `json:"-"`
 }
 
 type User struct {
@@ -26,6 +39,17 @@ type UserSecret struct {
 	Login     string `db:"login"`
 	Secret    string `db:"secret"`
 	SessionID string `db:"session_id"`
+	UserAgent string `db:"user_agent"`
+	ClientIP  string `db:"client_ip"`
+}
+
+type Session struct {
+	ID         string    `json:"id" db:"session_id"`
+	UserAgent  string    `json:"userAgent" db:"user_agent"`
+	ClientIP   string    `json:"clientIp" db:"client_ip"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	LastSeenAt time.Time `json:"lastSeenAt" db:"last_seen_at"`
+	Current    bool      `json:"current" db:"-"`
 }
 
 type AccInfo struct {
@@ -36,12 +60,76 @@ type AccInfo struct {
 	} `json:"inventory"`
 	CoinHistory struct {
 		Received []struct {
-			FromUser string `json:"fromUser" db:"sender"`
-			Amount   int    `json:"amount" db:"amount"`
+			FromUser     string `json:"fromUser" db:"sender"`
+			FromUserName string `json:"fromUserName,omitempty" db:"sender_name"`
+			Amount       int    `json:"amount" db:"amount"`
 		} `json:"received"`
 		Sent []struct {
-			ToUser string `json:"toUser" db:"recipient"`
-			Amount int    `json:"amount" db:"amount"`
+			ToUser     string `json:"toUser" db:"recipient"`
+			ToUserName string `json:"toUserName,omitempty" db:"recipient_name"`
+			Amount     int    `json:"amount" db:"amount"`
 		} `json:"sent"`
 	} `json:"coinHistory"`
 }
+
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type RefreshToken struct {
+	TokenHash string    `db:"token_hash"`
+	Login     string    `db:"login"`
+	SessionID string    `db:"session_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type Invite struct {
+	Code      string    `json:"code" db:"-"`
+	CodeHash  string    `json:"-" db:"code_hash"`
+	CreatedBy string    `json:"-" db:"created_by"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+}
+
+type PasswordReset struct {
+	Token     string    `json:"token" db:"-"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	Login     string    `json:"login" db:"login"`
+	CreatedBy string    `json:"-" db:"created_by"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+}
+
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// APIKey - long-lived key of integration acting on behalf of login within its scopes
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	Key        string     `json:"key,omitempty" db:"-"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Login      string     `json:"-" db:"login"`
+	Name       string     `json:"name" db:"name"`
+	Scopes     []string   `json:"scopes" db:"-"`
+	Roles      []string   `json:"-" db:"-"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+}
+
+type TOTP struct {
+	Login        string     `db:"login"`
+	Secret       string     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+}
+
+// TOTPEnrollment - secret to be added to authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/keyset"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	refreshTokenBytes      = 32
+)
+
+func (m *ModelAuth) accessTokenTTL() time.Duration {
+	if m.cfg.AccessTokenTTL > 0 {
+		return m.cfg.AccessTokenTTL
+	}
+	return defaultAccessTokenTTL
+}
+
+func (m *ModelAuth) refreshTokenTTL() time.Duration {
+	if m.cfg.RefreshTokenTTL > 0 {
+		return m.cfg.RefreshTokenTTL
+	}
+	return defaultRefreshTokenTTL
+}
+
+// createSession creates new session of already authenticated user.
+// Session gets its own secret for access JWTs and the first refresh token of the session's family.
+// Returns ErrConflict or err
+func (m *ModelAuth) createSession(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
+
+	key, err := genKey(64)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "genKey")
+
+		return structs.AuthTokens{}, err
+	}
+
+	sessionID, err := uuid.NewV4()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "NewV4")
+
+		return structs.AuthTokens{}, err
+	}
+
+	userSecret := structs.UserSecret{
+		Login:     info.Login,
+		Secret:    key,
+		SessionID: sessionID.String(),
+		UserAgent: info.UserAgent,
+		ClientIP:  info.ClientIP,
+	}
+	err = m.as.CreateUserSecret(ctx, userSecret)
+	if err != nil {
+		if errors.Is(err, ErrConflict) {
+			// По идее такая ситуация может быть, когда секрет одинаковы, но шанс такого очень мал.
+			return structs.AuthTokens{}, ErrConflict
+		}
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "CreateUserSecret")
+
+		return structs.AuthTokens{}, err
+	}
+
+	refreshToken, err := genRefreshToken()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "genRefreshToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	err = m.as.CreateRefreshToken(ctx, structs.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		Login:     userSecret.Login,
+		SessionID: userSecret.SessionID,
+		ExpiresAt: time.Now().Add(m.refreshTokenTTL()),
+	})
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "CreateRefreshToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	accessToken, err := m.signAccessToken(ctx, userSecret)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "createSession", "signAccessToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	return structs.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(m.accessTokenTTL().Seconds()),
+	}, nil
+}
+
+// signAccessToken signs short-lived JWT with the session secret or with the active server key in asymmetric mode.
+// Roles are read on every signing, so granted or revoked roles apply after the next refresh.
+func (m *ModelAuth) signAccessToken(ctx context.Context, userSecret structs.UserSecret) (string, error) {
+	lgr := logger.GetLogger()
+
+	roles, err := m.getUserRoles(ctx, userSecret.Login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "signAccessToken", "getUserRoles")
+
+		return "", err
+	}
+
+	payload := jwt.MapClaims{
+		"sub":   userSecret.Login,
+		"sID":   userSecret.SessionID,
+		"roles": roles,
+		"exp":   time.Now().Add(m.accessTokenTTL()).Unix(),
+	}
+	if m.cfg.JWT.Issuer != "" {
+		payload["iss"] = m.cfg.JWT.Issuer
+	}
+
+	var tokStr string
+	if m.keys != nil {
+		tokStr, err = m.keys.Sign(payload)
+	} else {
+		tokStr, err = jwt.NewWithClaims(jwt.SigningMethodHS256, payload).SignedString([]byte(userSecret.Secret))
+	}
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "signAccessToken", "SignedString")
+
+		return "", err
+	}
+
+	return tokStr, nil
+}
+
+// AccessTokenKey returns key to verify access token of the given session.
+// HMAC tokens are verified with the session secret in both modes, so tokens issued before switching to asymmetric mode stay valid until they expire.
+func (m *ModelAuth) AccessTokenKey(token *jwt.Token, userSecret structs.UserSecret) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(userSecret.Secret), nil
+	}
+	if m.keys == nil {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return m.keys.Key(token)
+}
+
+// JWKS returns public keys of asymmetric mode, empty in HMAC mode
+func (m *ModelAuth) JWKS() keyset.JWKS {
+	return m.keys.JWKS()
+}
+
+// Refresh exchanges refresh token for new access and refresh tokens.
+// Presenting already rotated refresh token revokes the whole session with all its tokens.
+// Returns ErrInvalidToken, ErrRefreshTokenReused or err
+func (m *ModelAuth) Refresh(ctx context.Context, refreshToken string) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
+
+	newRefreshToken, err := genRefreshToken()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "Refresh", "genRefreshToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	userSecret, err := m.as.RotateRefreshToken(ctx, hashToken(refreshToken), structs.RefreshToken{
+		TokenHash: hashToken(newRefreshToken),
+		ExpiresAt: time.Now().Add(m.refreshTokenTTL()),
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return structs.AuthTokens{}, ErrInvalidToken
+		}
+		if errors.Is(err, ErrRefreshTokenReused) {
+			lgr.Warn("refresh token reuse, session revoked", "ModelAuth", "Refresh", "RotateRefreshToken")
+
+			return structs.AuthTokens{}, ErrRefreshTokenReused
+		}
+		lgr.Error(err.Error(), "ModelAuth", "Refresh", "RotateRefreshToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	accessToken, err := m.signAccessToken(ctx, userSecret)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "Refresh", "signAccessToken")
+
+		return structs.AuthTokens{}, err
+	}
+
+	return structs.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(m.accessTokenTTL().Seconds()),
+	}, nil
+}
+
+// genRefreshToken generates opaque refresh token. Only its hash is stored.
+func genRefreshToken() (string, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
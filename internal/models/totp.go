@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/Kapeland/task-Avito/internal/utils/totp"
+)
+
+const (
+	defaultTOTPIssuer  = "Avito Shop"
+	recoveryCodesCount = 10
+	recoveryCodeLength = 10
+)
+
+func (m *ModelAuth) totpIssuer() string {
+	if m.cfg.TOTP.Issuer != "" {
+		return m.cfg.TOTP.Issuer
+	}
+	return defaultTOTPIssuer
+}
+
+// EnrollTOTP generates new secret of user. It isn't required on login until confirmed.
+// Returns ErrTOTPEnabled, ErrUserNotFound or err
+func (m *ModelAuth) EnrollTOTP(ctx context.Context, login string) (structs.TOTPEnrollment, error) {
+	lgr := logger.GetLogger()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "EnrollTOTP", "GenerateSecret")
+
+		return structs.TOTPEnrollment{}, err
+	}
+
+	err = m.as.UpsertPendingTOTP(ctx, login, secret)
+	if err != nil {
+		if errors.Is(err, ErrTOTPEnabled) || errors.Is(err, ErrUserNotFound) {
+			return structs.TOTPEnrollment{}, err
+		}
+		lgr.Error(err.Error(), "ModelAuth", "EnrollTOTP", "UpsertPendingTOTP")
+
+		return structs.TOTPEnrollment{}, err
+	}
+
+	return structs.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(m.totpIssuer(), login, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables second factor if code matches pending secret.
+// Returns recovery codes, they are shown once and only their hashes are stored.
+// Returns ErrTOTPNotEnabled, ErrTOTPEnabled, ErrInvalidOTP or err
+func (m *ModelAuth) ConfirmTOTP(ctx context.Context, login string, code string) ([]string, error) {
+	lgr := logger.GetLogger()
+
+	userTOTP, err := m.as.GetTOTP(ctx, login)
+	if err != nil {
+		if errors.Is(err, ErrTOTPNotEnabled) {
+			return nil, ErrTOTPNotEnabled
+		}
+		lgr.Error(err.Error(), "ModelAuth", "ConfirmTOTP", "GetTOTP")
+
+		return nil, err
+	}
+	if userTOTP.ConfirmedAt != nil {
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := totp.Validate(userTOTP.Secret, code, time.Now(), m.cfg.TOTP.Skew)
+	if !ok {
+		return nil, ErrInvalidOTP
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for len(codes) < recoveryCodesCount {
+		recoveryCode, err := genKey(recoveryCodeLength)
+		if err != nil {
+			lgr.Error(err.Error(), "ModelAuth", "ConfirmTOTP", "genKey")
+
+			return nil, err
+		}
+		codes = append(codes, recoveryCode)
+		hashes = append(hashes, hashToken(recoveryCode))
+	}
+
+	err = m.as.ConfirmTOTP(ctx, login, step, hashes)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOTP) {
+			return nil, ErrInvalidOTP
+		}
+		lgr.Error(err.Error(), "ModelAuth", "ConfirmTOTP", "ConfirmTOTP")
+
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns second factor off. Current code or recovery code is required.
+// Returns ErrTOTPNotEnabled, ErrOTPRequired, ErrInvalidOTP or err
+func (m *ModelAuth) DisableTOTP(ctx context.Context, login string, code string) error {
+	lgr := logger.GetLogger()
+
+	userTOTP, err := m.as.GetTOTP(ctx, login)
+	if err != nil {
+		if errors.Is(err, ErrTOTPNotEnabled) {
+			return ErrTOTPNotEnabled
+		}
+		lgr.Error(err.Error(), "ModelAuth", "DisableTOTP", "GetTOTP")
+
+		return err
+	}
+	if userTOTP.ConfirmedAt != nil {
+		if err := m.verifyOTP(ctx, userTOTP, code); err != nil {
+			return err
+		}
+	}
+
+	err = m.as.DeleteTOTP(ctx, login)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelAuth", "DisableTOTP", "DeleteTOTP")
+
+		return err
+	}
+
+	return nil
+}
+
+// checkSecondFactor requires one-time code from users with confirmed TOTP
+// Returns ErrOTPRequired, ErrInvalidOTP or err
+func (m *ModelAuth) checkSecondFactor(ctx context.Context, info structs.RegisterUserInfo) error {
+	userTOTP, err := m.as.GetTOTP(ctx, info.Login)
+	if err != nil {
+		if errors.Is(err, ErrTOTPNotEnabled) {
+			return nil
+		}
+		return err
+	}
+	if userTOTP.ConfirmedAt == nil {
+		return nil
+	}
+
+	return m.verifyOTP(ctx, userTOTP, info.OTP)
+}
+
+// verifyOTP accepts either TOTP code not used before or unused recovery code
+func (m *ModelAuth) verifyOTP(ctx context.Context, userTOTP structs.TOTP, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrOTPRequired
+	}
+
+	if step, ok := totp.Validate(userTOTP.Secret, code, time.Now(), m.cfg.TOTP.Skew); ok {
+		return m.as.UseTOTPStep(ctx, userTOTP.Login, step)
+	}
+
+	return m.as.UseRecoveryCode(ctx, userTOTP.Login, hashToken(code))
+}
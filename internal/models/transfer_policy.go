@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+)
+
+// checkTransferStatic checks what doesn't depend on previous transfers
+func checkTransferStatic(policy config.Transfers, operation structs.SendCoinInfo) error {
+	if operation.Amount <= 0 {
+		return ErrNonPositiveAmount
+	}
+	if operation.From == operation.To {
+		return ErrSelfTransfer
+	}
+	if policy.MaxAmount > 0 && operation.Amount > policy.MaxAmount {
+		return ErrAmountAboveMax
+	}
+	return nil
+}
+
+// checkTransferLimits checks that transfer fits into rolling limits together with already sent coins.
+// Storage calls it inside transfer transaction, so concurrent transfers can't exceed limits together.
+func checkTransferLimits(policy config.Transfers, operation structs.SendCoinInfo, stats structs.TransferStats) error {
+	if policy.PerRecipientDailyLimit > 0 && stats.SentToRecipientDay+operation.Amount > policy.PerRecipientDailyLimit {
+		return ErrRecipientLimitExceeded
+	}
+	if policy.DailyLimit > 0 && stats.SentDay+operation.Amount > policy.DailyLimit {
+		return ErrDailyLimitExceeded
+	}
+	if policy.WeeklyLimit > 0 && stats.SentWeek+operation.Amount > policy.WeeklyLimit {
+		return ErrWeeklyLimitExceeded
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTransferStatic(t *testing.T) {
+	policy := config.Transfers{MaxAmount: 500}
+
+	tests := []struct {
+		name   string
+		policy config.Transfers
+		op     structs.SendCoinInfo
+		want   error
+	}{
+		{"ok", policy, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 500}, nil},
+		{"zero", policy, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 0}, ErrNonPositiveAmount},
+		{"negative", policy, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: -5}, ErrNonPositiveAmount},
+		{"self", policy, structs.SendCoinInfo{From: "user1user1", To: "user1user1", Amount: 5}, ErrSelfTransfer},
+		{"above max", policy, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 501}, ErrAmountAboveMax},
+		{"no max", config.Transfers{}, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 100000}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checkTransferStatic(tt.policy, tt.op))
+		})
+	}
+}
+
+func TestCheckTransferLimits(t *testing.T) {
+	policy := config.Transfers{DailyLimit: 1000, WeeklyLimit: 3000, PerRecipientDailyLimit: 500}
+	op := structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 100}
+
+	tests := []struct {
+		name   string
+		policy config.Transfers
+		stats  structs.TransferStats
+		want   error
+	}{
+		{"fits", policy, structs.TransferStats{SentDay: 900, SentWeek: 2900, SentToRecipientDay: 400}, nil},
+		{"recipient", policy, structs.TransferStats{SentDay: 401, SentWeek: 401, SentToRecipientDay: 401}, ErrRecipientLimitExceeded},
+		{"daily", policy, structs.TransferStats{SentDay: 901, SentWeek: 901}, ErrDailyLimitExceeded},
+		{"weekly", policy, structs.TransferStats{SentDay: 0, SentWeek: 2901}, ErrWeeklyLimitExceeded},
+		{"no limits", config.Transfers{}, structs.TransferStats{SentDay: 1e6, SentWeek: 1e6, SentToRecipientDay: 1e6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checkTransferLimits(tt.policy, op, tt.stats))
+		})
+	}
+}
@@ -3,31 +3,72 @@ package models
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
 )
 
 type UsersStorager interface {
-	CreateUserST(ctx context.Context, info structs.RegisterUserInfo) error
+	CreateUserST(ctx context.Context, info structs.RegisterUserInfo, inviteHash string) error
+	CreateInviteST(ctx context.Context, invite structs.Invite) error
 	CheckPasswordST(ctx context.Context, info structs.AuthUserInfo) (bool, error)
-	SendCoinST(ctx context.Context, operation structs.SendCoinInfo) error
-	BuyItemST(ctx context.Context, item string, login string) error
+	UpdatePasswordST(ctx context.Context, info structs.AuthUserInfo) error
+	CreatePasswordResetST(ctx context.Context, reset structs.PasswordReset) error
+	RedeemPasswordResetST(ctx context.Context, tokenHash string, newPswd string) (string, error)
+	// SendCoinST calls checkLimits with coins sent by sender inside transfer transaction, after his account is locked
+	SendCoinST(ctx context.Context, operation structs.SendCoinInfo, checkLimits func(structs.TransferStats) error) error
+	BuyItemST(ctx context.Context, item string, login string, idempotencyKey string) error
 	GetInfoST(ctx context.Context, login string) (structs.AccInfo, error)
+	GetRolesST(ctx context.Context, login string) ([]string, error)
+	GrantRoleST(ctx context.Context, login string, role string) error
+	RevokeRoleST(ctx context.Context, login string, role string) error
+	SetUserStatusST(ctx context.Context, login string, status string) error
+	AnonymizeUserST(ctx context.Context, login string, anonLogin string) error
+	DeleteUserST(ctx context.Context, login string) error
+	GetProfileST(ctx context.Context, login string) (structs.Profile, error)
+	UpdateProfileST(ctx context.Context, change structs.ProfileChange) error
+	SearchUsersST(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, error)
+	GetHistoryST(ctx context.Context, filter structs.HistoryFilter) ([]structs.LedgerEntry, error)
+	CreateIdempotencyKeyST(ctx context.Context, rec structs.IdempotencyRecord, retention time.Duration, lease time.Duration) (structs.IdempotencyRecord, bool, error)
+	SaveIdempotentResponseST(ctx context.Context, login string, key string, statusCode int, response []byte) error
+	DeleteIdempotencyKeyST(ctx context.Context, login string, key string) error
+	ReverseOperationST(ctx context.Context, reversal structs.Reversal) ([]structs.LedgerEntry, error)
 }
 
+// SendCoin sends coins if transfer policy allows it.
+// Returns ErrNonPositiveAmount, ErrSelfTransfer, ErrAmountAboveMax, ErrDailyLimitExceeded, ErrWeeklyLimitExceeded,
+// ErrRecipientLimitExceeded, ErrUserNotFound, ErrInsufficientBalance, ErrUserDeactivated, ErrIdempotencyInProgress or err
 func (m *ModelUsers) SendCoin(ctx context.Context, operation structs.SendCoinInfo) error {
 	lgr := logger.GetLogger()
 
-	err := m.us.SendCoinST(ctx, operation)
+	policy := m.cfg.Transfers
+	if err := checkTransferStatic(policy, operation); err != nil {
+		return err
+	}
+	var checkLimits func(structs.TransferStats) error
+	if policy.DailyLimit > 0 || policy.WeeklyLimit > 0 || policy.PerRecipientDailyLimit > 0 {
+		checkLimits = func(stats structs.TransferStats) error {
+			return checkTransferLimits(policy, operation, stats)
+		}
+	}
+
+	err := m.us.SendCoinST(ctx, operation, checkLimits)
 
 	if err != nil {
+		if errors.Is(err, ErrRecipientLimitExceeded) || errors.Is(err, ErrDailyLimitExceeded) ||
+			errors.Is(err, ErrWeeklyLimitExceeded) || errors.Is(err, ErrIdempotencyInProgress) {
+			return err
+		}
 		if errors.Is(err, ErrUserNotFound) {
 			return ErrUserNotFound
 		}
 		if errors.Is(err, ErrInsufficientBalance) {
 			return ErrInsufficientBalance
 		}
+		if errors.Is(err, ErrUserDeactivated) {
+			return ErrUserDeactivated
+		}
 
 		lgr.Error(err.Error(), "ModelUsers", "SendCoin", "SendCoinDB")
 
@@ -37,15 +78,20 @@ func (m *ModelUsers) SendCoin(ctx context.Context, operation structs.SendCoinInf
 	return nil
 }
 
-func (m *ModelUsers) BuyItem(ctx context.Context, item string, login string) error {
+// BuyItem buys item. Non-empty idempotencyKey is marked as done together with purchase.
+// Returns ErrNoSuchItem, ErrIdempotencyInProgress or err
+func (m *ModelUsers) BuyItem(ctx context.Context, item string, login string, idempotencyKey string) error {
 	lgr := logger.GetLogger()
 
-	err := m.us.BuyItemST(ctx, item, login)
+	err := m.us.BuyItemST(ctx, item, login, idempotencyKey)
 
 	if err != nil {
 		if errors.Is(err, ErrNoSuchItem) {
 			return ErrNoSuchItem
 		}
+		if errors.Is(err, ErrIdempotencyInProgress) {
+			return ErrIdempotencyInProgress
+		}
 
 		lgr.Error(err.Error(), "ModelUsers", "BuyItemDB", "BuyItemDB")
 
@@ -55,7 +101,9 @@ func (m *ModelUsers) BuyItem(ctx context.Context, item string, login string) err
 	return nil
 }
 
-func (m *ModelUsers) Info(ctx context.Context, login string) (structs.AccInfo, error) {
+// Info returns wallet, inventory and coin history of user.
+// Display names of counterparties are returned only with withNames.
+func (m *ModelUsers) Info(ctx context.Context, login string, withNames bool) (structs.AccInfo, error) {
 	lgr := logger.GetLogger()
 
 	accInfo, err := m.us.GetInfoST(ctx, login)
@@ -70,5 +118,14 @@ func (m *ModelUsers) Info(ctx context.Context, login string) (structs.AccInfo, e
 		return structs.AccInfo{}, err
 	}
 
+	if !withNames {
+		for i := range accInfo.CoinHistory.Received {
+			accInfo.CoinHistory.Received[i].FromUserName = ""
+		}
+		for i := range accInfo.CoinHistory.Sent {
+			accInfo.CoinHistory.Sent[i].ToUserName = ""
+		}
+	}
+
 	return accInfo, nil
 }
@@ -0,0 +1,274 @@
+package servers
+
+import (
+	"errors"
+	"net/http"
+	"unicode"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminServer struct {
+	A models.AuthModelManager
+	C models.CatalogModelManager
+	U models.UsersModelManager
+}
+
+const maxItemNameLen = 64
+
+func isItemNameValid(s string) bool {
+	if s == "" || len(s) > maxItemNameLen {
+		return false
+	}
+	for _, c := range s {
+		if !unicode.IsDigit(c) && !unicode.IsLetter(c) && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *AdminServer) CreateItem(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req svStruct.CreateItemReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if !isItemNameValid(req.Name) || req.Price == nil || *req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad item name or price"})
+		return
+	}
+
+	item := structs.Item{Name: req.Name, Price: *req.Price, Available: true}
+	if req.Available != nil {
+		item.Available = *req.Available
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	created, err := s.C.CreateItem(c.Request.Context(), item, login)
+	if err != nil {
+		if errors.Is(err, models.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "CreateItem", "CreateItem")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (s *AdminServer) UpdateItem(c *gin.Context) {
+	var req svStruct.UpdateItemReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.Price == nil && req.Available == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "nothing to update"})
+		return
+	}
+	if req.Price != nil && *req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "price can't be negative"})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	item, err := s.C.UpdateItem(c.Request.Context(), structs.ItemChange{
+		Name:      c.Param("item"),
+		Price:     req.Price,
+		Available: req.Available,
+		Action:    structs.ItemActionUpdate,
+		ChangedBy: login,
+	})
+	itemChangedResponse(c, item, err, "UpdateItem")
+}
+
+func (s *AdminServer) RepriceItem(c *gin.Context) {
+	var req svStruct.RepriceItemReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.Price == nil || *req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "price must be a non-negative integer"})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	item, err := s.C.RepriceItem(c.Request.Context(), c.Param("item"), *req.Price, login)
+	itemChangedResponse(c, item, err, "RepriceItem")
+}
+
+func (s *AdminServer) RetireItem(c *gin.Context) {
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	item, err := s.C.RetireItem(c.Request.Context(), c.Param("item"), login)
+	itemChangedResponse(c, item, err, "RetireItem")
+}
+
+func itemChangedResponse(c *gin.Context, item structs.Item, err error, method string) {
+	lgr := logger.GetLogger()
+
+	if err != nil {
+		if errors.Is(err, models.ErrNoSuchItem) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", method, "itemChangedResponse")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+func (s *AdminServer) GrantRole(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	err := s.A.GrantRole(c.Request.Context(), c.Param("login"), c.Param("role"))
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "GrantRole", "GrantRole")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *AdminServer) RevokeRole(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	err := s.A.RevokeRole(c.Request.Context(), c.Param("login"), c.Param("role"))
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "RevokeRole", "RevokeRole")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *AdminServer) CreateInvite(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	invite, err := s.A.CreateInvite(c.Request.Context(), login)
+	if err != nil {
+		lgr.Error(err.Error(), "AdminServer", "CreateInvite", "CreateInvite")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, invite)
+}
+
+func (s *AdminServer) CreatePasswordReset(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	reset, err := s.A.CreatePasswordReset(c.Request.Context(), c.Param("login"), login)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "CreatePasswordReset", "CreatePasswordReset")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, reset)
+}
+
+func (s *AdminServer) DeactivateUser(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+	if c.Param("login") == login {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "can't deactivate yourself"})
+		return
+	}
+
+	err := s.A.DeactivateUser(c.Request.Context(), c.Param("login"))
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "DeactivateUser", "DeactivateUser")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *AdminServer) ActivateUser(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	err := s.A.ActivateUser(c.Request.Context(), c.Param("login"))
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "ActivateUser", "ActivateUser")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// DeleteUser anonymizes user. ?mode=hard removes him completely.
+func (s *AdminServer) DeleteUser(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+	if c.Param("login") == login {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "can't delete yourself"})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "anonymize")
+	if mode != "anonymize" && mode != "hard" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "mode must be anonymize or hard"})
+		return
+	}
+
+	err := s.A.DeleteUser(c.Request.Context(), c.Param("login"), mode == "hard")
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "AdminServer", "DeleteUser", "DeleteUser")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -3,10 +3,13 @@ package servers
 import (
 	"context"
 	"errors"
+	"math"
 	"net/http"
-	"unicode"
+	"strconv"
+	"time"
 
 	structs2 "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/credpolicy"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
 	"github.com/gin-gonic/gin"
 
@@ -16,108 +19,484 @@ import (
 
 type AuthServer struct {
 	A models.AuthModelManager
+	P *credpolicy.Policy
 }
 
-func isPasswordValid(s string) bool {
-	if len(s) < 8 {
-		return false
+func (s *AuthServer) Register(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var regInfo structs2.RegisterReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&regInfo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
 	}
-	symbols := 0
-	number, upper, lower, special := false, false, false, false
-	for _, c := range s {
-		switch {
-		case unicode.IsDigit(c):
-			number = true
-			symbols++
-		case unicode.IsUpper(c):
-			upper = true
-			symbols++
-		case unicode.IsLower(c):
-			lower = true
-			symbols++
-		case unicode.IsPunct(c) || unicode.IsSymbol(c):
-			special = true
-			symbols++
-		case unicode.IsLetter(c):
-			symbols++
-		}
-	}
-	if symbols < 8 || !(number && special && upper && lower) {
-		return false
+
+	if regInfo.Username == "" || regInfo.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad login or pass"})
+		return
 	}
 
-	return true
-}
+	userInfo := structs.RegisterUserInfo{
+		Login:     regInfo.Username,
+		Pswd:      regInfo.Password,
+		OTP:       regInfo.OTP,
+		UserAgent: c.Request.UserAgent(),
+		ClientIP:  c.ClientIP(),
+	}
 
-func isLoginValid(s string) bool {
-	if len(s) < 8 {
-		return false
+	var tokens structs.AuthTokens
+	var err error
+	if reasons := s.P.Check(userInfo.Login, userInfo.Pswd); len(reasons) > 0 { // bad password or login
+		// Политика действует только для новых аккаунтов, существующие пользователи входят со старым паролем
+		tokens, err = s.A.LogIn(c.Request.Context(), userInfo)
+		if errors.Is(err, models.ErrBadCredentials) {
+			lgr.Info("Bad pass or login", "authServer", "Register", "Check")
+
+			c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad login or pass", "reasons": reasons})
+			return
+		}
+	} else {
+		tokens, err = s.register(c.Request.Context(), userInfo)
 	}
-	for _, c := range s {
-		if !unicode.IsDigit(c) && !unicode.IsLetter(c) {
-			return false
+	if err != nil {
+		if errors.Is(err, models.ErrBadCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"errors": "Wrong login or password"})
+			return
+		}
+		if abortLocked(c, err) || abortSecondFactor(c, err) {
+			return
 		}
+		lgr.Error("internal server error", "authServer", "Register", "register")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *AuthServer) register(ctx context.Context, info structs.RegisterUserInfo) (structs.AuthTokens, error) {
+	lgr := logger.GetLogger()
+
+	tokens, err := s.A.RegisterUser(ctx, info)
+	if err != nil && !errors.Is(err, models.ErrBadCredentials) && !errors.Is(err, models.ErrTooManyAttempts) &&
+		!errors.Is(err, models.ErrOTPRequired) && !errors.Is(err, models.ErrInvalidOTP) {
+		lgr.Error(err.Error(), "authServer", "register", "RegisterUser")
+	}
+
+	return tokens, err
+}
+
+// abortLocked responds 429 with Retry-After if err is *models.LockoutError
+func abortLocked(c *gin.Context, err error) bool {
+	var lockoutErr *models.LockoutError
+	if !errors.As(err, &lockoutErr) {
+		return false
 	}
 
+	retryAfter := int(math.Ceil(lockoutErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"errors": lockoutErr.Error()})
+
 	return true
 }
 
-func isLoginPswdValid(login string, password string) bool {
-	return isLoginValid(login) && isPasswordValid(password)
+// abortSecondFactor responds 401 if login needs one-time code or it's wrong
+func abortSecondFactor(c *gin.Context, err error) bool {
+	if !errors.Is(err, models.ErrOTPRequired) && !errors.Is(err, models.ErrInvalidOTP) {
+		return false
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"errors": err.Error()})
+
+	return true
 }
 
-func (s *AuthServer) Register(c *gin.Context) {
+func (s *AuthServer) SignUp(c *gin.Context) {
 	lgr := logger.GetLogger()
 
-	var regInfo structs2.RegisterReqBody
+	var req structs2.SignUpReqBody
 
-	if err := c.ShouldBindBodyWithJSON(&regInfo); err != nil {
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 		return
 	}
 
-	login := regInfo.Username
-	pswd := regInfo.Password
+	if reasons := s.P.Check(req.Username, req.Password); len(reasons) > 0 { // bad password or login
+		lgr.Info("Bad pass or login", "authServer", "SignUp", "Check")
+
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad login or pass", "reasons": reasons})
+		return
+	}
+
+	userInfo := structs.RegisterUserInfo{
+		Login:     req.Username,
+		Pswd:      req.Password,
+		UserAgent: c.Request.UserAgent(),
+		ClientIP:  c.ClientIP(),
+	}
+
+	tokens, err := s.A.SignUp(c.Request.Context(), userInfo, req.InviteCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserConflict):
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrInviteRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrInvalidInvite):
+			c.JSON(http.StatusForbidden, gin.H{"errors": err.Error()})
+		default:
+			lgr.Error(err.Error(), "authServer", "SignUp", "SignUp")
+			c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *AuthServer) Login(c *gin.Context) {
+	lgr := logger.GetLogger()
 
-	if !isLoginPswdValid(login, pswd) { // bad password or login
-		lgr.Info("Bad pass or login", "authServer", "Register", "IsLoginPswdValid")
+	var req structs2.RegisterReqBody
 
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.Username == "" || req.Password == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad login or pass"})
 		return
 	}
 
 	userInfo := structs.RegisterUserInfo{
-		Login: login,
-		Pswd:  pswd,
+		Login:     req.Username,
+		Pswd:      req.Password,
+		OTP:       req.OTP,
+		UserAgent: c.Request.UserAgent(),
+		ClientIP:  c.ClientIP(),
+	}
+
+	tokens, err := s.A.LogIn(c.Request.Context(), userInfo)
+	if err != nil {
+		if errors.Is(err, models.ErrBadCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"errors": "Wrong login or password"})
+			return
+		}
+		if abortLocked(c, err) || abortSecondFactor(c, err) {
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "Login", "LogIn")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
 	}
-	tokStr, status := s.register(c.Request.Context(), userInfo)
 
-	if status == http.StatusUnauthorized {
-		c.JSON(status, gin.H{"errors": "Wrong login or password"})
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *AuthServer) Refresh(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.RefreshReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 		return
 	}
-	if status == http.StatusInternalServerError {
-		lgr.Error("internal server error", "authServer", "Register", "register")
-		c.JSON(status, gin.H{"errors": "internal server error"})
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "refreshToken can't be empty"})
+		return
+	}
+
+	tokens, err := s.A.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) || errors.Is(err, models.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "Refresh", "Refresh")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *AuthServer) Logout(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+	sessionID := c.Keys["sID"].(string)
+
+	err := s.A.Logout(c.Request.Context(), login, sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) { // Сессию успели отозвать параллельным запросом
+			c.JSON(http.StatusUnauthorized, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "Logout", "Logout")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
 		return
 	}
+	c.Status(http.StatusOK)
+}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokStr})
+func (s *AuthServer) LogoutAll(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	err := s.A.LogoutAll(c.Request.Context(), login)
+	if err != nil {
+		lgr.Error(err.Error(), "authServer", "LogoutAll", "LogoutAll")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.Status(http.StatusOK)
 }
 
-func (s *AuthServer) register(ctx context.Context, info structs.RegisterUserInfo) (string, int) {
+func (s *AuthServer) Sessions(c *gin.Context) {
 	lgr := logger.GetLogger()
 
-	tokStr, err := s.A.RegisterUser(ctx, info)
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+	sessionID := c.Keys["sID"].(string)
+
+	sessions, err := s.A.ListSessions(c.Request.Context(), login)
+	if err != nil {
+		lgr.Error(err.Error(), "authServer", "Sessions", "ListSessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sessionID
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (s *AuthServer) RevokeSession(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	err := s.A.Logout(c.Request.Context(), login, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": "session not found"})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "RevokeSession", "Logout")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *AuthServer) ChangePassword(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.ChangePasswordReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+	sessionID := c.Keys["sID"].(string)
+
+	if reasons := s.P.CheckPassword(login, req.NewPassword); len(reasons) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad new password", "reasons": reasons})
+		return
+	}
+
+	err := s.A.ChangePassword(c.Request.Context(), login, sessionID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrBadCredentials) {
-			return "", http.StatusUnauthorized
+			c.JSON(http.StatusForbidden, gin.H{"errors": "Wrong old password"})
+			return
+		}
+		if abortLocked(c, err) {
+			return
 		}
+		lgr.Error(err.Error(), "authServer", "ChangePassword", "ChangePassword")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
 
-		lgr.Error(err.Error(), "authServer", "register", "RegisterUser")
+func (s *AuthServer) ResetPassword(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.ResetPasswordReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad token or new password"})
+		return
+	}
+	// Логин станет известен только по токену, поэтому правило "пароль не содержит логин" здесь не проверяется
+	if reasons := s.P.CheckPassword("", req.NewPassword); len(reasons) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad token or new password", "reasons": reasons})
+		return
+	}
+
+	err := s.A.ResetPassword(c.Request.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"errors": "invalid or expired reset token"})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "ResetPassword", "ResetPassword")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
 
-		return "", http.StatusInternalServerError
+func (s *AuthServer) CreateAPIKey(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.CreateAPIKeyReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.Name == "" || len(req.Name) > 64 || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "Bad name or expiresInDays"})
+		return
 	}
 
-	return tokStr, http.StatusOK
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	apiKey, err := s.A.CreateAPIKey(c.Request.Context(), login, req.Name, req.Scopes,
+		time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "CreateAPIKey", "CreateAPIKey")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func (s *AuthServer) APIKeys(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	apiKeys, err := s.A.ListAPIKeys(c.Request.Context(), login)
+	if err != nil {
+		lgr.Error(err.Error(), "authServer", "APIKeys", "ListAPIKeys")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"apiKeys": apiKeys})
+}
+
+func (s *AuthServer) RevokeAPIKey(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	err := s.A.RevokeAPIKey(c.Request.Context(), login, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": "api key not found"})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "RevokeAPIKey", "RevokeAPIKey")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *AuthServer) EnrollTOTP(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	enrollment, err := s.A.EnrollTOTP(c.Request.Context(), login)
+	if err != nil {
+		if errors.Is(err, models.ErrTOTPEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "authServer", "EnrollTOTP", "EnrollTOTP")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+func (s *AuthServer) ConfirmTOTP(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.TOTPCodeReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	recoveryCodes, err := s.A.ConfirmTOTP(c.Request.Context(), login, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTOTPNotEnabled):
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrTOTPEnabled):
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrInvalidOTP):
+			c.JSON(http.StatusForbidden, gin.H{"errors": err.Error()})
+		default:
+			lgr.Error(err.Error(), "authServer", "ConfirmTOTP", "ConfirmTOTP")
+			c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+func (s *AuthServer) DisableTOTP(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	var req structs2.TOTPCodeReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	err := s.A.DisableTOTP(c.Request.Context(), login, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTOTPNotEnabled):
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrOTPRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrInvalidOTP):
+			c.JSON(http.StatusForbidden, gin.H{"errors": err.Error()})
+		default:
+			lgr.Error(err.Error(), "authServer", "DisableTOTP", "DisableTOTP")
+			c.JSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+		}
+		return
+	}
+	c.Status(http.StatusOK)
 }
@@ -4,17 +4,25 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Kapeland/task-Avito/internal/models"
 	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/services/servers/middleware"
 	svStruct "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
 	"github.com/gin-gonic/gin"
 )
 
 type ShopServer struct {
-	U models.UsersModelManager
-	A models.AuthModelManager
+	U   models.UsersModelManager
+	A   models.AuthModelManager
+	C   models.CatalogModelManager
+	Dir config.Directory
 }
 
 func (s *ShopServer) SendCoin(c *gin.Context) {
@@ -27,14 +35,31 @@ func (s *ShopServer) SendCoin(c *gin.Context) {
 		return
 	}
 
+	if msg := checkTransferMemo(operation.Message, operation.Tag); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": msg})
+		return
+	}
+
 	login := c.Keys["login"].(string) // Получаем из JWT middleware
 
-	err := s.sendCoin(c.Request.Context(), operation, login)
+	err := s.sendCoin(c.Request.Context(), operation, login, c.GetString(middleware.IdempotencyKey))
 	if err != nil {
-		if errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrInsufficientBalance) {
+		if errors.Is(err, models.ErrIdempotencyInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrInsufficientBalance) ||
+			errors.Is(err, models.ErrUserDeactivated) || errors.Is(err, models.ErrNonPositiveAmount) ||
+			errors.Is(err, models.ErrSelfTransfer) {
 			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 			return
 		}
+		// Запрос корректен, но не проходит по лимитам
+		if errors.Is(err, models.ErrAmountAboveMax) || errors.Is(err, models.ErrDailyLimitExceeded) ||
+			errors.Is(err, models.ErrWeeklyLimitExceeded) || errors.Is(err, models.ErrRecipientLimitExceeded) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": err.Error()})
+			return
+		}
 
 		lgr.Error(err.Error(), "ShopServer", "SendCoin", "sendCoin")
 		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
@@ -43,13 +68,44 @@ func (s *ShopServer) SendCoin(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
-func (s *ShopServer) sendCoin(ctx context.Context, operation svStruct.SendCoinReqBody, fromLogin string) error {
+const (
+	maxTransferMessageLen = 280
+	maxTransferTagLen     = 32
+)
+
+// checkTransferMemo returns description of invalid message or tag, or empty string.
+// Tag is one word: category like "teamwork" or emoji.
+func checkTransferMemo(message string, tag string) string {
+	if utf8.RuneCountInString(message) > maxTransferMessageLen {
+		return "message is too long"
+	}
+	for _, r := range message {
+		if unicode.IsControl(r) && r != '\n' {
+			return "message can't contain control characters"
+		}
+	}
+
+	if utf8.RuneCountInString(tag) > maxTransferTagLen {
+		return "tag is too long"
+	}
+	for _, r := range tag {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "tag must be a single word or emoji"
+		}
+	}
+	return ""
+}
+
+func (s *ShopServer) sendCoin(ctx context.Context, operation svStruct.SendCoinReqBody, fromLogin string, idempotencyKey string) error {
 	lgr := logger.GetLogger()
 
 	err := s.U.SendCoin(ctx, structs.SendCoinInfo{
-		From:   fromLogin,
-		To:     operation.To,
-		Amount: operation.Amount,
+		From:           fromLogin,
+		To:             operation.To,
+		Amount:         operation.Amount,
+		Message:        strings.TrimSpace(operation.Message),
+		Tag:            operation.Tag,
+		IdempotencyKey: idempotencyKey,
 	})
 	if err != nil {
 		lgr.Error(err.Error(), "ShopServer", "sendCoin", "SendCoin")
@@ -68,12 +124,16 @@ func (s *ShopServer) BuyItem(c *gin.Context) {
 
 	login := c.Keys["login"].(string) // Получаем из JWT middleware
 
-	err := s.buyItem(c.Request.Context(), item, login)
+	err := s.buyItem(c.Request.Context(), item, login, c.GetString(middleware.IdempotencyKey))
 	if err != nil {
 		if errors.Is(err, models.ErrNoSuchItem) {
 			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 			return
 		}
+		if errors.Is(err, models.ErrIdempotencyInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+			return
+		}
 		lgr.Error(err.Error(), "ShopServer", "BuyItemDB", "buyItem")
 		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
 		return
@@ -81,9 +141,9 @@ func (s *ShopServer) BuyItem(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
-func (s *ShopServer) buyItem(ctx context.Context, item string, login string) error {
+func (s *ShopServer) buyItem(ctx context.Context, item string, login string, idempotencyKey string) error {
 	lgr := logger.GetLogger()
-	err := s.U.BuyItem(ctx, item, login)
+	err := s.U.BuyItem(ctx, item, login, idempotencyKey)
 	if err != nil {
 		lgr.Error(err.Error(), "ShopServer", "buyItem", "BuyItemDB")
 	}
@@ -95,7 +155,10 @@ func (s *ShopServer) Info(c *gin.Context) {
 
 	login := c.Keys["login"].(string) // Получаем из JWT middleware
 
-	accInfo, err := s.info(c.Request.Context(), login)
+	// ?names=true добавляет отображаемые имена в историю переводов
+	withNames := c.Query("names") == "true"
+
+	accInfo, err := s.info(c.Request.Context(), login, withNames)
 	if err != nil {
 		if errors.Is(err, models.ErrUserNotFound) {
 			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
@@ -108,11 +171,50 @@ func (s *ShopServer) Info(c *gin.Context) {
 	c.JSON(http.StatusOK, accInfo)
 }
 
-func (s *ShopServer) info(ctx context.Context, login string) (structs.AccInfo, error) {
+func (s *ShopServer) info(ctx context.Context, login string, withNames bool) (structs.AccInfo, error) {
 	lgr := logger.GetLogger()
-	accInfo, err := s.U.Info(ctx, login)
+	accInfo, err := s.U.Info(ctx, login, withNames)
 	if err != nil {
 		lgr.Error(err.Error(), "ShopServer", "info", "Info")
 	}
 	return accInfo, err
 }
+
+func (s *ShopServer) Items(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	filter := structs.ItemsFilter{Sort: c.Query("sort")}
+
+	switch filter.Sort {
+	case structs.ItemsSortByName, structs.ItemsSortByPriceAsc, structs.ItemsSortByPriceDesc:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "sort must be price_asc or price_desc"})
+		return
+	}
+
+	if maxPriceStr, ok := c.GetQuery("maxPrice"); ok {
+		maxPrice, err := strconv.Atoi(maxPriceStr)
+		if err != nil || maxPrice < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": "maxPrice must be a non-negative integer"})
+			return
+		}
+		filter.MaxPrice = &maxPrice
+	}
+
+	items, err := s.items(c.Request.Context(), filter)
+	if err != nil {
+		lgr.Error(err.Error(), "ShopServer", "Items", "items")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (s *ShopServer) items(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error) {
+	lgr := logger.GetLogger()
+	items, err := s.C.ListItems(ctx, filter)
+	if err != nil {
+		lgr.Error(err.Error(), "ShopServer", "items", "ListItems")
+	}
+	return items, err
+}
@@ -0,0 +1,93 @@
+package servers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// parseHistoryTime accepts RFC 3339 timestamp or date, date means its midnight UTC
+func parseHistoryTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// History returns individual ledger entries of user with cursor pagination.
+// Filters: direction=in|out, counterparty, type, from, to.
+func (s *ShopServer) History(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	filter := structs.HistoryFilter{
+		Login:        login,
+		Direction:    c.Query("direction"),
+		Counterparty: c.Query("counterparty"),
+		Type:         c.Query("type"),
+		Limit:        defaultHistoryLimit,
+	}
+
+	switch filter.Direction {
+	case "", structs.HistoryDirectionIn, structs.HistoryDirectionOut:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "direction must be in or out"})
+		return
+	}
+
+	switch filter.Type {
+	case "", structs.LedgerTypeTransfer, structs.LedgerTypePurchase, structs.LedgerTypeGrant, structs.LedgerTypeRefund:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "type must be transfer, purchase, grant or refund"})
+		return
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		str, ok := c.GetQuery(p.name)
+		if !ok {
+			continue
+		}
+		t, err := parseHistoryTime(str)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": p.name + " must be RFC 3339 time or YYYY-MM-DD date"})
+			return
+		}
+		*p.dst = &t
+	}
+
+	if limitStr, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": "limit must be from 1 to " + strconv.Itoa(maxHistoryLimit)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	page, err := s.U.History(c.Request.Context(), filter, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "ShopServer", "History", "History")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
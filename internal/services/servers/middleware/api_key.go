@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// CheckJWTOrAPIKey authenticates request by X-API-Key header if it's given, otherwise works as CheckJWT.
+// API key requests get login, roles of key owner and scopes of the key, but no sID.
+func CheckJWTOrAPIKey(a models.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
+	checkJWT := CheckJWT(a, lgr)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			checkJWT(c)
+			return
+		}
+
+		apiKey, err := a.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidToken) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"errors": "Invalid API key"})
+				return
+			}
+			lgr.Error(err.Error(), "api_key", "CheckJWTOrAPIKey", "AuthenticateAPIKey")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+			return
+		}
+
+		c.Set("login", apiKey.Login)
+		c.Set("apiKeyID", apiKey.ID)
+		c.Set("roles", apiKey.Roles)
+		c.Set("scopes", apiKey.Scopes)
+		c.Next()
+	}
+}
+
+// RequireScope lets API key request through only if the key has the given scope.
+// JWT requests act with full rights of user and aren't limited by scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isAPIKey := c.Keys["scopes"].([]string)
+		if !isAPIKey || slices.Contains(scopes, scope) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"errors": "Forbidden"})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		isAPIKey bool
+		required string
+		want     int
+	}{
+		{
+			name:     "JWT request",
+			isAPIKey: false,
+			required: "coins:send",
+			want:     http.StatusOK,
+		},
+		{
+			name:     "API key has scope",
+			scopes:   []string{"info:read", "coins:send"},
+			isAPIKey: true,
+			required: "coins:send",
+			want:     http.StatusOK,
+		},
+		{
+			name:     "API key lacks scope",
+			scopes:   []string{"info:read"},
+			isAPIKey: true,
+			required: "coins:send",
+			want:     http.StatusForbidden,
+		},
+		{
+			name:     "API key without scopes",
+			scopes:   []string{},
+			isAPIKey: true,
+			required: "info:read",
+			want:     http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.isAPIKey {
+					c.Set("scopes", tt.scopes)
+				}
+				c.Next()
+			}, RequireScope(tt.required), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
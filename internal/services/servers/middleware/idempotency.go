@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKey is gin context key of Idempotency-Key of started request.
+// Handler passes it to storage, so key is marked as done in the same transaction as operation.
+const IdempotencyKey = "idempotencyKey"
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyManager is implemented by models.ModelUsers
+type IdempotencyManager interface {
+	StartIdempotent(ctx context.Context, login string, key string, requestHash string) (structs.IdempotencyRecord, bool, error)
+	FinishIdempotent(ctx context.Context, login string, key string, statusCode int, response []byte) error
+	ReleaseIdempotent(ctx context.Context, login string, key string) error
+}
+
+// bodyRecorder copies response body to replay it later
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes request with Idempotency-Key header executed at most once per login.
+// Retry returns saved result, the same key with another body gets 409.
+// Failed with 5xx requests are forgotten, so they can be retried. Must go after CheckJWT or CheckJWTOrAPIKey.
+// Handler must pass IdempotencyKey from context to storage and respond to success with empty 200.
+func Idempotency(m IdempotencyManager, lgr *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": "Idempotency-Key is too long"})
+			return
+		}
+
+		login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Путь входит в хэш: /buy/:item передаёт товар в URL
+		h := sha256.New()
+		h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		h.Write(body)
+		requestHash := hex.EncodeToString(h.Sum(nil))
+
+		rec, started, err := m.StartIdempotent(c.Request.Context(), login, key, requestHash)
+		if err != nil {
+			if errors.Is(err, models.ErrIdempotencyKeyReused) || errors.Is(err, models.ErrIdempotencyInProgress) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"errors": err.Error()})
+				return
+			}
+			lgr.Error(err.Error(), "idempotency", "Idempotency", "StartIdempotent")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"errors": "internal server error"})
+			return
+		}
+
+		if !started {
+			c.Header(idempotentReplayHeader, "true")
+			if rec.StatusCode == nil {
+				// Операция закоммичена, а ответ сохранить не успели. Успешные ответы здесь без тела
+				c.AbortWithStatus(http.StatusOK)
+				return
+			}
+			if len(rec.Response) == 0 {
+				c.AbortWithStatus(*rec.StatusCode)
+				return
+			}
+			c.Data(*rec.StatusCode, "application/json; charset=utf-8", rec.Response)
+			c.Abort()
+			return
+		}
+
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Set(IdempotencyKey, key)
+
+		c.Next()
+
+		// Контекст запроса может быть уже отменён клиентом, а результат сохранить нужно
+		ctx := context.WithoutCancel(c.Request.Context())
+		if w.Status() >= http.StatusInternalServerError {
+			err = m.ReleaseIdempotent(ctx, login, key)
+		} else {
+			err = m.FinishIdempotent(ctx, login, key, w.Status(), w.body.Bytes())
+		}
+		if err != nil {
+			lgr.Error(err.Error(), "idempotency", "Idempotency", "FinishIdempotent")
+		}
+	}
+}
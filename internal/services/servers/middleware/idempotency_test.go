@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeIdempotency struct {
+	recs map[string]structs.IdempotencyRecord
+}
+
+func (f *fakeIdempotency) StartIdempotent(_ context.Context, login string, key string, requestHash string) (structs.IdempotencyRecord, bool, error) {
+	rec, ok := f.recs[login+key]
+	if !ok {
+		f.recs[login+key] = structs.IdempotencyRecord{Login: login, Key: key, RequestHash: requestHash}
+		return f.recs[login+key], true, nil
+	}
+	if rec.RequestHash != requestHash {
+		return structs.IdempotencyRecord{}, false, models.ErrIdempotencyKeyReused
+	}
+	if rec.StatusCode == nil && rec.OperationID == nil {
+		return structs.IdempotencyRecord{}, false, models.ErrIdempotencyInProgress
+	}
+	return rec, false, nil
+}
+
+func (f *fakeIdempotency) FinishIdempotent(_ context.Context, login string, key string, statusCode int, response []byte) error {
+	rec := f.recs[login+key]
+	rec.StatusCode = &statusCode
+	rec.Response = response
+	f.recs[login+key] = rec
+	return nil
+}
+
+func (f *fakeIdempotency) ReleaseIdempotent(_ context.Context, login string, key string) error {
+	delete(f.recs, login+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		c.Set("login", "user1user1")
+		c.Next()
+	}, Idempotency(&fakeIdempotency{recs: map[string]structs.IdempotencyRecord{}}, nil), func(c *gin.Context) {
+		calls++
+		c.JSON(status, gin.H{"calls": calls})
+	})
+
+	do := func(key string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("k1", `{"amount":1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+
+	// Повтор возвращает сохранённый ответ без повторного выполнения
+	w = do("k1", `{"amount":1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayHeader))
+	assert.Equal(t, 1, calls)
+
+	w = do("k1", `{"amount":2}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 1, calls)
+
+	// Без ключа запрос выполняется каждый раз
+	do("", `{"amount":1}`)
+	do("", `{"amount":1}`)
+	assert.Equal(t, 3, calls)
+
+	// 5xx не сохраняется, запрос можно повторить
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, do("k2", `{}`).Code)
+	status = http.StatusOK
+	assert.Equal(t, http.StatusOK, do("k2", `{}`).Code)
+	assert.Equal(t, 5, calls)
+}
+
+func TestIdempotency_CommittedWithoutResponse(t *testing.T) {
+	calls := 0
+	operationID := "op"
+	fake := &fakeIdempotency{recs: map[string]structs.IdempotencyRecord{}}
+
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		c.Set("login", "user1user1")
+		c.Next()
+	}, Idempotency(fake, nil), func(c *gin.Context) {
+		calls++
+		assert.Equal(t, "k1", c.GetString(IdempotencyKey))
+		// Падение сервера после коммита операции: ответ не сохранён
+		rec := fake.recs["user1user1k1"]
+		rec.OperationID = &operationID
+		fake.recs["user1user1k1"] = rec
+		panic("crash")
+	})
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(`{}`))
+		req.Header.Set(idempotencyKeyHeader, "k1")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Panics(t, func() { do() })
+
+	w := do()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayHeader))
+	assert.Equal(t, 1, calls)
+}
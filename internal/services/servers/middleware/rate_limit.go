@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Kapeland/task-Avito/internal/utils/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits requests per login. Must go after CheckJWT or CheckJWTOrAPIKey.
+// Nil limiter lets everything through.
+func RateLimit(l *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		login, _ := c.Keys["login"].(string)
+
+		ok, wait := l.Allow(login)
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"errors": "too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
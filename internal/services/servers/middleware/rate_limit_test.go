@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/utils/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set("login", c.Query("login"))
+		c.Next()
+	}, RateLimit(ratelimit.New(1, 2)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(login string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?login="+login, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do("user1user1").Code)
+	assert.Equal(t, http.StatusOK, do("user1user1").Code)
+
+	w := do("user1user1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, do("user1user2").Code)
+}
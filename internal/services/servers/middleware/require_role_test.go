@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		userRoles []string
+		required  []string
+		want      int
+	}{
+		{
+			name:      "Has required role",
+			userRoles: []string{"employee", "admin"},
+			required:  []string{"admin"},
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Has one of required roles",
+			userRoles: []string{"employee", "hr"},
+			required:  []string{"admin", "hr"},
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Has no required role",
+			userRoles: []string{"employee"},
+			required:  []string{"admin"},
+			want:      http.StatusForbidden,
+		},
+		{
+			name:      "No roles in context",
+			userRoles: nil,
+			required:  []string{"admin"},
+			want:      http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.userRoles != nil {
+					c.Set("roles", tt.userRoles)
+				}
+				c.Next()
+			}, RequireRole(tt.required...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
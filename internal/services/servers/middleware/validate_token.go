@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/Kapeland/task-Avito/internal/models"
@@ -42,11 +41,9 @@ func CheckJWT(a models.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
 			return
 		}
 
+		// Сессия проверяется и в асимметричном режиме, иначе отозванный токен жил бы до exp
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(userSecret.Secret), nil
+			return a.AccessTokenKey(token, userSecret)
 		})
 
 		switch {
@@ -61,11 +58,32 @@ func CheckJWT(a models.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": "Invalid token"})
 			return
 		}
+		if err := a.TouchSession(c.Request.Context(), login, sessionID); err != nil {
+			// Время последней активности не критично, запрос можно обслужить
+			lgr.Error(err.Error(), "validate_token", "CheckJWT", "TouchSession")
+		}
+
 		c.Set("login", login)
+		c.Set("sID", sessionID)
+		c.Set("roles", getRoles(token.Claims.(jwt.MapClaims)))
 		c.Next()
 	}
 }
 
+// getRoles get roles from verified token claims. Tokens issued before roles were introduced have none.
+func getRoles(claims jwt.MapClaims) []string {
+	rawRoles, _ := claims["roles"].([]interface{})
+
+	roles := make([]string, 0, len(rawRoles))
+	for _, r := range rawRoles {
+		if role, ok := r.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
 func getUnverifiedTokenClaims(tokenStr string, lgr *logger.Logger) (jwt.MapClaims, error) {
 	parser := jwt.Parser{}
 	unverToken, _, err := parser.ParseUnverified(tokenStr,
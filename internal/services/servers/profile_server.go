@@ -0,0 +1,150 @@
+package servers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxDisplayNameLen = 64
+	maxDepartmentLen  = 64
+	maxAvatarURLLen   = 512
+	maxBioLen         = 1000
+
+	minSearchQueryLen = 2
+	maxSearchQueryLen = 64
+)
+
+func (s *ShopServer) Profile(c *gin.Context) {
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	profile, err := s.U.Profile(c.Request.Context(), login)
+	profileResponse(c, profile, err, "Profile")
+}
+
+func (s *ShopServer) UserProfile(c *gin.Context) {
+	profile, err := s.U.Profile(c.Request.Context(), c.Param("login"))
+	profileResponse(c, profile, err, "UserProfile")
+}
+
+func (s *ShopServer) UpdateProfile(c *gin.Context) {
+	var req svStruct.UpdateProfileReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	if req.DisplayName == nil && req.Department == nil && req.AvatarURL == nil && req.Bio == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "nothing to update"})
+		return
+	}
+	if msg := checkProfileChange(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": msg})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	profile, err := s.U.UpdateProfile(c.Request.Context(), structs.ProfileChange{
+		Login:       login,
+		DisplayName: req.DisplayName,
+		Department:  req.Department,
+		AvatarURL:   req.AvatarURL,
+		Bio:         req.Bio,
+	})
+	profileResponse(c, profile, err, "UpdateProfile")
+}
+
+// checkProfileChange returns description of the first invalid field or empty string
+func checkProfileChange(req svStruct.UpdateProfileReqBody) string {
+	if req.DisplayName != nil && utf8.RuneCountInString(*req.DisplayName) > maxDisplayNameLen {
+		return "displayName is too long"
+	}
+	if req.Department != nil && utf8.RuneCountInString(*req.Department) > maxDepartmentLen {
+		return "department is too long"
+	}
+	if req.Bio != nil && utf8.RuneCountInString(*req.Bio) > maxBioLen {
+		return "bio is too long"
+	}
+	// Пустая строка убирает аватар
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		if len(*req.AvatarURL) > maxAvatarURLLen {
+			return "avatarUrl is too long"
+		}
+		u, err := url.Parse(*req.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "avatarUrl must be an absolute http(s) URL"
+		}
+	}
+	return ""
+}
+
+func profileResponse(c *gin.Context, profile structs.Profile, err error, method string) {
+	lgr := logger.GetLogger()
+
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+			return
+		}
+		lgr.Error(err.Error(), "ShopServer", method, method)
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// SearchUsers finds users by prefix or similarity of login and display name.
+// Page is set by limit and offset, nextOffset is returned when there are more results.
+func (s *ShopServer) SearchUsers(c *gin.Context) {
+	lgr := logger.GetLogger()
+
+	query := strings.TrimSpace(c.Query("q"))
+	if n := utf8.RuneCountInString(query); n < minSearchQueryLen || n > maxSearchQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "q must be from 2 to 64 characters"})
+		return
+	}
+
+	limit, maxLimit := s.Dir.SearchLimits()
+	if limitStr, ok := c.GetQuery("limit"); ok {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": "limit must be from 1 to " + strconv.Itoa(maxLimit)})
+			return
+		}
+	}
+	offset := 0
+	if offsetStr, ok := c.GetQuery("offset"); ok {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": "offset must be a non-negative integer"})
+			return
+		}
+	}
+
+	users, more, err := s.U.SearchUsers(c.Request.Context(), structs.UsersFilter{Query: query, Limit: limit, Offset: offset})
+	if err != nil {
+		lgr.Error(err.Error(), "ShopServer", "SearchUsers", "SearchUsers")
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp := gin.H{"users": users}
+	if more {
+		resp["nextOffset"] = offset + limit
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,33 @@
+package servers
+
+import (
+	"strings"
+	"testing"
+
+	svStruct "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestCheckProfileChange(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   svStruct.UpdateProfileReqBody
+		valid bool
+	}{
+		{"ok", svStruct.UpdateProfileReqBody{DisplayName: ptr("Иван Петров"), AvatarURL: ptr("https://cdn.example.com/a.png")}, true},
+		{"clear avatar", svStruct.UpdateProfileReqBody{AvatarURL: ptr("")}, true},
+		{"long name", svStruct.UpdateProfileReqBody{DisplayName: ptr(strings.Repeat("я", maxDisplayNameLen+1))}, false},
+		{"long bio", svStruct.UpdateProfileReqBody{Bio: ptr(strings.Repeat("b", maxBioLen+1))}, false},
+		{"relative avatar", svStruct.UpdateProfileReqBody{AvatarURL: ptr("/img/a.png")}, false},
+		{"javascript avatar", svStruct.UpdateProfileReqBody{AvatarURL: ptr("javascript:alert(1)")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, checkProfileChange(tt.req) == "")
+		})
+	}
+}
@@ -3,13 +3,15 @@ package servers
 import (
 	"net/http"
 
+	"github.com/Kapeland/task-Avito/internal/models"
 	"github.com/Kapeland/task-Avito/internal/services/servers/middleware"
 	"github.com/Kapeland/task-Avito/internal/utils/config"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/Kapeland/task-Avito/internal/utils/ratelimit"
 	"github.com/gin-gonic/gin"
 )
 
-func CreateRESTServer(implAuth AuthServer, implShop ShopServer, restAddr string) *http.Server {
+func CreateRESTServer(implAuth AuthServer, implShop ShopServer, implAdmin AdminServer, restAddr string) *http.Server {
 	cfg := config.GetConfig()
 
 	if !cfg.Project.Debug {
@@ -21,20 +23,73 @@ func CreateRESTServer(implAuth AuthServer, implShop ShopServer, restAddr string)
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	storeGr := router.Group("/api", middleware.CheckJWT(implAuth.A, &lgr))
+	searchLimiter := ratelimit.New(cfg.Directory.SearchPerMinute, cfg.Directory.SearchBurst)
+
+	storeGr := router.Group("/api", middleware.CheckJWTOrAPIKey(implAuth.A, &lgr))
 	{
-		storeGr.GET("/info", implShop.Info)
+		storeGr.GET("/info", middleware.RequireScope(models.ScopeInfoRead), implShop.Info)
+		storeGr.GET("/history", middleware.RequireScope(models.ScopeInfoRead), implShop.History)
+		storeGr.POST("/sendCoin", middleware.RequireScope(models.ScopeCoinsSend), middleware.Idempotency(implShop.U, &lgr), implShop.SendCoin)
+		storeGr.GET("/buy/:item", middleware.RequireScope(models.ScopeShopBuy), middleware.Idempotency(implShop.U, &lgr), implShop.BuyItem)
+		storeGr.GET("/profile", middleware.RequireScope(models.ScopeInfoRead), implShop.Profile)
+		storeGr.GET("/users/:login", middleware.RequireScope(models.ScopeInfoRead), implShop.UserProfile)
+		storeGr.GET("/users", middleware.RequireScope(models.ScopeInfoRead), middleware.RateLimit(searchLimiter), implShop.SearchUsers)
 	}
 	authGR := router.Group("/api")
 	{
-		authGR.POST("/auth", implAuth.Register)
+		if cfg.Auth.LegacyCombinedAuth {
+			authGR.POST("/auth", implAuth.Register)
+		} else {
+			authGR.POST("/auth", implAuth.Login)
+		}
+		authGR.POST("/register", implAuth.SignUp)
+		authGR.POST("/login", implAuth.Login)
+		authGR.POST("/auth/refresh", implAuth.Refresh)
+		authGR.POST("/password/reset", implAuth.ResetPassword)
+	}
+
+	catalogGr := router.Group("/api")
+	{
+		catalogGr.GET("/items", implShop.Items)
 	}
 
 	operGr := router.Group("/api", middleware.CheckJWT(implAuth.A, &lgr))
 	{
-		operGr.POST("/sendCoin", implShop.SendCoin)
-		operGr.GET("/buy/:item", implShop.BuyItem)
+		operGr.POST("/logout", implAuth.Logout)
+		operGr.POST("/logout/all", implAuth.LogoutAll)
+		operGr.GET("/sessions", implAuth.Sessions)
+		operGr.DELETE("/sessions/:id", implAuth.RevokeSession)
+		operGr.POST("/password", implAuth.ChangePassword)
+		operGr.POST("/apikeys", implAuth.CreateAPIKey)
+		operGr.GET("/apikeys", implAuth.APIKeys)
+		operGr.DELETE("/apikeys/:id", implAuth.RevokeAPIKey)
+		operGr.POST("/2fa/totp", implAuth.EnrollTOTP)
+		operGr.POST("/2fa/totp/confirm", implAuth.ConfirmTOTP)
+		operGr.DELETE("/2fa/totp", implAuth.DisableTOTP)
+		operGr.PATCH("/profile", implShop.UpdateProfile)
+		operGr.POST("/operations/:id/undo", implShop.UndoTransfer)
+
+	}
 
+	adminGr := router.Group("/api/admin", middleware.CheckJWT(implAuth.A, &lgr), middleware.RequireRole(models.RoleAdmin))
+	{
+		adminGr.POST("/items", implAdmin.CreateItem)
+		adminGr.PATCH("/items/:item", implAdmin.UpdateItem)
+		adminGr.PUT("/items/:item/price", implAdmin.RepriceItem)
+		adminGr.DELETE("/items/:item", implAdmin.RetireItem)
+
+		adminGr.PUT("/users/:login/roles/:role", implAdmin.GrantRole)
+		adminGr.DELETE("/users/:login/roles/:role", implAdmin.RevokeRole)
+		adminGr.POST("/users/:login/password-reset", implAdmin.CreatePasswordReset)
+		adminGr.POST("/users/:login/deactivate", implAdmin.DeactivateUser)
+		adminGr.POST("/users/:login/activate", implAdmin.ActivateUser)
+		adminGr.DELETE("/users/:login", implAdmin.DeleteUser)
+		adminGr.POST("/operations/:id/reverse", implAdmin.ReverseOperation)
+	}
+
+	hrGr := router.Group("/api/admin", middleware.CheckJWTOrAPIKey(implAuth.A, &lgr), middleware.RequireRole(models.RoleAdmin, models.RoleHR))
+	{
+		hrGr.POST("/invites", middleware.RequireScope(models.ScopeInvitesCreate), implAdmin.CreateInvite)
 	}
 	restServer := &http.Server{
 		Addr:    restAddr,
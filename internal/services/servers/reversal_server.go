@@ -0,0 +1,64 @@
+package servers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Avito/internal/services/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// ReverseOperation posts compensating entries for transfer or purchase. Body with reason is optional.
+func (s *AdminServer) ReverseOperation(c *gin.Context) {
+	var req svStruct.ReverseOperationReqBody
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(req.Reason) > maxTransferMessageLen {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": "reason is too long"})
+		return
+	}
+
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	entries, err := s.U.ReverseOperation(c.Request.Context(), c.Param("id"), login, req.Reason)
+	reversalResponse(c, entries, err, "AdminServer", "ReverseOperation")
+}
+
+// UndoTransfer lets sender take his transfer back during undo window
+func (s *ShopServer) UndoTransfer(c *gin.Context) {
+	login := c.Keys["login"].(string) // Получаем из JWT middleware
+
+	entries, err := s.U.UndoTransfer(c.Request.Context(), login, c.Param("id"))
+	reversalResponse(c, entries, err, "ShopServer", "UndoTransfer")
+}
+
+func reversalResponse(c *gin.Context, entries []structs.LedgerEntry, err error, server string, method string) {
+	lgr := logger.GetLogger()
+
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrOperationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrAlreadyReversed):
+			c.JSON(http.StatusConflict, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrUndoDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"errors": err.Error()})
+		case errors.Is(err, models.ErrNotReversible), errors.Is(err, models.ErrUndoExpired),
+			errors.Is(err, models.ErrCoinsSpent), errors.Is(err, models.ErrInsufficientBalance):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": err.Error()})
+		default:
+			lgr.Error(err.Error(), server, method, method)
+			c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
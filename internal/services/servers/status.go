@@ -6,10 +6,17 @@ import (
 	"net/http"
 	"sync/atomic"
 
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/utils/config"
 )
 
-func CreateStatusServer(cfg *config.Config, isReady *atomic.Value) *http.Server {
+// CacheStatser gives counters of in-process cache
+type CacheStatser interface {
+	Stats() structs.CacheStats
+}
+
+func CreateStatusServer(cfg *config.Config, isReady *atomic.Value, a models.AuthModelManager, sessionCache CacheStatser) *http.Server {
 	statusAddr := fmt.Sprintf("%s:%v", cfg.Status.Host, cfg.Status.Port)
 
 	if !cfg.Project.Debug {
@@ -24,6 +31,8 @@ func CreateStatusServer(cfg *config.Config, isReady *atomic.Value) *http.Server
 	router.GET(cfg.Status.LivenessPath, livenessHandler)
 	router.GET(cfg.Status.ReadinessPath, readinessHandler(isReady))
 	router.GET(cfg.Status.VersionPath, versionHandler(cfg))
+	router.GET("/.well-known/jwks.json", jwksHandler(a))
+	router.GET("/stats/session-cache", cacheStatsHandler(sessionCache))
 
 	statusServer := &http.Server{
 		Addr:    statusAddr,
@@ -56,3 +65,17 @@ func versionHandler(cfg *config.Config) gin.HandlerFunc {
 		c.JSON(http.StatusOK, data)
 	}
 }
+
+// jwksHandler publishes public keys so other services can verify access tokens offline
+func jwksHandler(a models.AuthModelManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, a.JWKS())
+	}
+}
+
+func cacheStatsHandler(sessionCache CacheStatser) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, sessionCache.Stats())
+	}
+}
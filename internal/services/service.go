@@ -13,6 +13,7 @@ import (
 	"github.com/Kapeland/task-Avito/internal/models"
 	"github.com/Kapeland/task-Avito/internal/services/servers"
 	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/Kapeland/task-Avito/internal/utils/credpolicy"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
 	"github.com/pkg/errors"
 )
@@ -20,22 +21,31 @@ import (
 type Service struct {
 	um models.UsersModelManager
 	am models.AuthModelManager
+	cm models.CatalogModelManager
+	sc servers.CacheStatser
 }
 
-func NewService(um models.UsersModelManager, am models.AuthModelManager) Service {
-	return Service{um: um, am: am}
+func NewService(um models.UsersModelManager, am models.AuthModelManager, cm models.CatalogModelManager, sc servers.CacheStatser) Service {
+	return Service{um: um, am: am, cm: cm, sc: sc}
 }
 
 func (s Service) Launch(cfg *config.Config, lgr *logger.Logger) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	implAuth := servers.AuthServer{A: s.am}
-	implShop := servers.ShopServer{U: s.um, A: s.am}
+	policy, err := credpolicy.New(cfg.Auth.Credentials)
+	if err != nil {
+		lgr.Error(err.Error(), "Service", "Launch", "credpolicy.New")
+		return err
+	}
+
+	implAuth := servers.AuthServer{A: s.am, P: policy}
+	implShop := servers.ShopServer{U: s.um, A: s.am, C: s.cm, Dir: cfg.Directory}
+	implAdmin := servers.AdminServer{A: s.am, C: s.cm, U: s.um}
 
 	restAddr := fmt.Sprintf("%s:%v", cfg.Rest.Host, cfg.Rest.Port)
 
-	restServer := servers.CreateRESTServer(implAuth, implShop, restAddr)
+	restServer := servers.CreateRESTServer(implAuth, implShop, implAdmin, restAddr)
 
 	go func() {
 		lgr.InfoMsg(fmt.Sprintf("REST server is running on %s", restAddr))
@@ -48,7 +58,7 @@ func (s Service) Launch(cfg *config.Config, lgr *logger.Logger) error {
 	isReady := &atomic.Value{}
 	isReady.Store(false)
 
-	statusServer := servers.CreateStatusServer(cfg, isReady)
+	statusServer := servers.CreateStatusServer(cfg, isReady, s.am, s.sc)
 
 	go func() {
 		statusAdrr := fmt.Sprintf("%s:%v", cfg.Status.Host, cfg.Status.Port)
@@ -1,11 +1,70 @@
 package structs
 
 type SendCoinReqBody struct {
-	To     string `json:"toUser"`
-	Amount int    `json:"amount"`
+	To      string `json:"toUser"`
+	Amount  int    `json:"amount"`
+	Message string `json:"message"`
+	Tag     string `json:"tag"`
 }
 
 type RegisterReqBody struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	OTP      string `json:"otp"`
+}
+
+type SignUpReqBody struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	InviteCode string `json:"inviteCode"`
+}
+
+type ChangePasswordReqBody struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type ResetPasswordReqBody struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+type RefreshReqBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TOTPCodeReqBody struct {
+	Code string `json:"code"`
+}
+
+type CreateAPIKeyReqBody struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+type CreateItemReqBody struct {
+	Name      string `json:"name"`
+	Price     *int   `json:"price"`
+	Available *bool  `json:"available"`
+}
+
+type UpdateItemReqBody struct {
+	Price     *int  `json:"price"`
+	Available *bool `json:"available"`
+}
+
+type RepriceItemReqBody struct {
+	Price *int `json:"price"`
+}
+
+type UpdateProfileReqBody struct {
+	DisplayName *string `json:"displayName"`
+	Department  *string `json:"department"`
+	AvatarURL   *string `json:"avatarUrl"`
+	Bio         *string `json:"bio"`
+}
+
+type ReverseOperationReqBody struct {
+	Reason string `json:"reason"`
 }
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models"
 	"github.com/Kapeland/task-Avito/internal/models/structs"
@@ -12,6 +13,28 @@ import (
 type AuthRepo interface {
 	CreateUserSecret(ctx context.Context, userSecret *structs.UserSecret) error
 	GetSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) (*structs.UserSecret, error)
+	CreateRefreshToken(ctx context.Context, token *structs.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, newToken *structs.RefreshToken) (*structs.UserSecret, error)
+	DeleteSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) error
+	DeleteSecretsByLogin(ctx context.Context, login string) error
+	DeleteSecretsByLoginExcept(ctx context.Context, login string, sessionID string) error
+	GetSessionsByLogin(ctx context.Context, login string) ([]structs.Session, error)
+	TouchSession(ctx context.Context, lgnSsn structs.UserSecret) error
+	GetLockedUntil(ctx context.Context, key string) (time.Time, error)
+	RegisterFailedAttempt(ctx context.Context, key string, window time.Duration) (int, error)
+	LockAttempts(ctx context.Context, key string, until time.Time) error
+	ResetAttempts(ctx context.Context, key string) error
+	CreateAPIKey(ctx context.Context, apiKey *structs.APIKey) error
+	GetAPIKeysByLogin(ctx context.Context, login string) ([]structs.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*structs.APIKey, error)
+	RevokeAPIKey(ctx context.Context, login string, id string) error
+	TouchAPIKey(ctx context.Context, id string) error
+	UpsertPendingTOTP(ctx context.Context, login string, secret string) error
+	GetTOTP(ctx context.Context, login string) (*structs.TOTP, error)
+	ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+	DeleteTOTP(ctx context.Context, login string) error
 }
 
 type AuthStorage struct {
@@ -47,3 +70,199 @@ func (s *AuthStorage) CreateUserSecret(ctx context.Context, userSecret structs.U
 	}
 	return nil
 }
+
+// DeleteUserSecretByLoginAndSession secret
+// Returns models.ErrNotFound or err
+func (s *AuthStorage) DeleteUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	err := s.authRepo.DeleteSecretByLoginAndSession(ctx, lgnSsn)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteUserSecretsByLogin secrets
+func (s *AuthStorage) DeleteUserSecretsByLogin(ctx context.Context, login string) error {
+	return s.authRepo.DeleteSecretsByLogin(ctx, login)
+}
+
+// GetSessionsByLogin sessions
+func (s *AuthStorage) GetSessionsByLogin(ctx context.Context, login string) ([]structs.Session, error) {
+	return s.authRepo.GetSessionsByLogin(ctx, login)
+}
+
+// TouchSession session
+func (s *AuthStorage) TouchSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	return s.authRepo.TouchSession(ctx, lgnSsn)
+}
+
+// CreateRefreshToken token
+func (s *AuthStorage) CreateRefreshToken(ctx context.Context, token structs.RefreshToken) error {
+	return s.authRepo.CreateRefreshToken(ctx, &token)
+}
+
+// RotateRefreshToken token
+// Returns models.ErrInvalidToken, models.ErrRefreshTokenReused (with login and session of revoked family) or err
+func (s *AuthStorage) RotateRefreshToken(ctx context.Context, oldHash string, newToken structs.RefreshToken) (structs.UserSecret, error) {
+	userSecret, err := s.authRepo.RotateRefreshToken(ctx, oldHash, &newToken)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.UserSecret{}, models.ErrInvalidToken
+		}
+		if errors.Is(err, repository.ErrTokenReused) {
+			return structs.UserSecret{Login: userSecret.Login, SessionID: userSecret.SessionID}, models.ErrRefreshTokenReused
+		}
+		return structs.UserSecret{}, err
+	}
+	return *userSecret, nil
+}
+
+// GetLockedUntil attempts
+func (s *AuthStorage) GetLockedUntil(ctx context.Context, key string) (time.Time, error) {
+	return s.authRepo.GetLockedUntil(ctx, key)
+}
+
+// RegisterFailedAttempt attempts
+func (s *AuthStorage) RegisterFailedAttempt(ctx context.Context, key string, window time.Duration) (int, error) {
+	return s.authRepo.RegisterFailedAttempt(ctx, key, window)
+}
+
+// LockAttempts attempts
+func (s *AuthStorage) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	return s.authRepo.LockAttempts(ctx, key, until)
+}
+
+// ResetAttempts attempts
+func (s *AuthStorage) ResetAttempts(ctx context.Context, key string) error {
+	return s.authRepo.ResetAttempts(ctx, key)
+}
+
+// DeleteUserSecretsByLoginExcept secrets
+func (s *AuthStorage) DeleteUserSecretsByLoginExcept(ctx context.Context, login string, sessionID string) error {
+	return s.authRepo.DeleteSecretsByLoginExcept(ctx, login, sessionID)
+}
+
+// CreateAPIKey key
+// Returns models.ErrConflict or err
+func (s *AuthStorage) CreateAPIKey(ctx context.Context, apiKey structs.APIKey) (structs.APIKey, error) {
+	err := s.authRepo.CreateAPIKey(ctx, &apiKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return structs.APIKey{}, models.ErrConflict
+		}
+		return structs.APIKey{}, err
+	}
+	return apiKey, nil
+}
+
+// GetAPIKeysByLogin keys
+func (s *AuthStorage) GetAPIKeysByLogin(ctx context.Context, login string) ([]structs.APIKey, error) {
+	return s.authRepo.GetAPIKeysByLogin(ctx, login)
+}
+
+// GetAPIKeyByHash key
+// Returns models.ErrInvalidToken or err
+func (s *AuthStorage) GetAPIKeyByHash(ctx context.Context, keyHash string) (structs.APIKey, error) {
+	apiKey, err := s.authRepo.GetAPIKeyByHash(ctx, keyHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.APIKey{}, models.ErrInvalidToken
+		}
+		return structs.APIKey{}, err
+	}
+	return *apiKey, nil
+}
+
+// RevokeAPIKey key
+// Returns models.ErrNotFound or err
+func (s *AuthStorage) RevokeAPIKey(ctx context.Context, login string, id string) error {
+	err := s.authRepo.RevokeAPIKey(ctx, login, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// TouchAPIKey key
+func (s *AuthStorage) TouchAPIKey(ctx context.Context, id string) error {
+	return s.authRepo.TouchAPIKey(ctx, id)
+}
+
+// UpsertPendingTOTP totp
+// Returns models.ErrTOTPEnabled, models.ErrUserNotFound or err
+func (s *AuthStorage) UpsertPendingTOTP(ctx context.Context, login string, secret string) error {
+	err := s.authRepo.UpsertPendingTOTP(ctx, login, secret)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return models.ErrTOTPEnabled
+		}
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetTOTP totp
+// Returns models.ErrTOTPNotEnabled or err
+func (s *AuthStorage) GetTOTP(ctx context.Context, login string) (structs.TOTP, error) {
+	totp, err := s.authRepo.GetTOTP(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.TOTP{}, models.ErrTOTPNotEnabled
+		}
+		return structs.TOTP{}, err
+	}
+	return *totp, nil
+}
+
+// ConfirmTOTP totp
+// Returns models.ErrInvalidOTP or err
+func (s *AuthStorage) ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error {
+	err := s.authRepo.ConfirmTOTP(ctx, login, step, recoveryHashes)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrInvalidOTP
+		}
+		return err
+	}
+	return nil
+}
+
+// UseTOTPStep totp
+// Returns models.ErrInvalidOTP or err
+func (s *AuthStorage) UseTOTPStep(ctx context.Context, login string, step int64) error {
+	err := s.authRepo.UseTOTPStep(ctx, login, step)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrInvalidOTP
+		}
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode totp
+// Returns models.ErrInvalidOTP or err
+func (s *AuthStorage) UseRecoveryCode(ctx context.Context, login string, codeHash string) error {
+	err := s.authRepo.UseRecoveryCode(ctx, login, codeHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrInvalidOTP
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteTOTP totp
+func (s *AuthStorage) DeleteTOTP(ctx context.Context, login string) error {
+	return s.authRepo.DeleteTOTP(ctx, login)
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+)
+
+const (
+	defaultSessionCacheTTL = 30 * time.Second
+	defaultTouchInterval   = time.Minute
+)
+
+type sessionKey struct {
+	login     string
+	sessionID string
+}
+
+type sessionEntry struct {
+	key       sessionKey
+	secret    structs.UserSecret
+	expiresAt time.Time
+	touchedAt time.Time
+}
+
+// CachedAuthStorage is bounded LRU/TTL cache of session secrets in front of AuthStorager.
+// Every revocation goes through it, so revoked session is dropped right away on this instance.
+// Other instances notice it after TTL.
+type CachedAuthStorage struct {
+	models.AuthStorager
+
+	size          int
+	ttl           time.Duration
+	touchInterval time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[sessionKey]*list.Element
+	byLogin map[string]map[string]struct{}
+	// gen растёт при каждой инвалидации, чтобы не положить в кэш секрет, прочитанный до отзыва
+	gen uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func NewCachedAuthStorage(as models.AuthStorager, cfg config.SessionCache) *CachedAuthStorage {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultSessionCacheTTL
+	}
+	touchInterval := cfg.TouchInterval
+	if touchInterval <= 0 {
+		touchInterval = defaultTouchInterval
+	}
+
+	return &CachedAuthStorage{
+		AuthStorager:  as,
+		size:          cfg.Size,
+		ttl:           ttl,
+		touchInterval: touchInterval,
+		now:           time.Now,
+		ll:            list.New(),
+		entries:       make(map[sessionKey]*list.Element),
+		byLogin:       make(map[string]map[string]struct{}),
+	}
+}
+
+// GetUserSecretByLoginAndSession secret from cache or storage
+// Returns models.ErrNotFound or err
+func (c *CachedAuthStorage) GetUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) (structs.UserSecret, error) {
+	key := sessionKey{login: lgnSsn.Login, sessionID: lgnSsn.SessionID}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*sessionEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			c.hits.Add(1)
+
+			return entry.secret, nil
+		}
+		c.removeElement(el)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	userSecret, err := c.AuthStorager.GetUserSecretByLoginAndSession(ctx, lgnSsn)
+	if err != nil {
+		return structs.UserSecret{}, err
+	}
+
+	c.mu.Lock()
+	if gen == c.gen {
+		c.add(key, userSecret)
+	}
+	c.mu.Unlock()
+
+	return userSecret, nil
+}
+
+// TouchSession session, not more often than once per touch interval for cached sessions
+func (c *CachedAuthStorage) TouchSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	key := sessionKey{login: lgnSsn.Login, sessionID: lgnSsn.SessionID}
+	now := c.now()
+
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		entry := el.Value.(*sessionEntry)
+		if now.Sub(entry.touchedAt) < c.touchInterval {
+			c.mu.Unlock()
+			return nil
+		}
+		entry.touchedAt = now
+	}
+	c.mu.Unlock()
+
+	return c.AuthStorager.TouchSession(ctx, lgnSsn)
+}
+
+// DeleteUserSecretByLoginAndSession secret
+// Returns models.ErrNotFound or err
+func (c *CachedAuthStorage) DeleteUserSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	drop := func(sessionID string) bool { return sessionID == lgnSsn.SessionID }
+	c.invalidate(lgnSsn.Login, drop)
+	defer c.invalidate(lgnSsn.Login, drop)
+
+	return c.AuthStorager.DeleteUserSecretByLoginAndSession(ctx, lgnSsn)
+}
+
+// DeleteUserSecretsByLogin secrets
+func (c *CachedAuthStorage) DeleteUserSecretsByLogin(ctx context.Context, login string) error {
+	drop := func(string) bool { return true }
+	c.invalidate(login, drop)
+	defer c.invalidate(login, drop)
+
+	return c.AuthStorager.DeleteUserSecretsByLogin(ctx, login)
+}
+
+// DeleteUserSecretsByLoginExcept secrets
+func (c *CachedAuthStorage) DeleteUserSecretsByLoginExcept(ctx context.Context, login string, sessionID string) error {
+	drop := func(s string) bool { return s != sessionID }
+	c.invalidate(login, drop)
+	defer c.invalidate(login, drop)
+
+	return c.AuthStorager.DeleteUserSecretsByLoginExcept(ctx, login, sessionID)
+}
+
+// RotateRefreshToken token. Reused token revokes its session, so it's dropped from cache too.
+// Returns models.ErrInvalidToken, models.ErrRefreshTokenReused or err
+func (c *CachedAuthStorage) RotateRefreshToken(ctx context.Context, oldHash string, newToken structs.RefreshToken) (structs.UserSecret, error) {
+	userSecret, err := c.AuthStorager.RotateRefreshToken(ctx, oldHash, newToken)
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		c.invalidate(userSecret.Login, func(s string) bool { return s == userSecret.SessionID })
+	}
+
+	return userSecret, err
+}
+
+// Stats returns cache counters. Nil cache (disabled) gives zeros.
+func (c *CachedAuthStorage) Stats() structs.CacheStats {
+	if c == nil {
+		return structs.CacheStats{}
+	}
+
+	c.mu.Lock()
+	size := c.ll.Len()
+	c.mu.Unlock()
+
+	return structs.CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// invalidate drops cached sessions of login matched by drop.
+// Delete wrappers call it before and after storage: the second call bumps gen once more,
+// so a miss that read the secret while it was being deleted won't put it into cache.
+func (c *CachedAuthStorage) invalidate(login string, drop func(sessionID string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for sessionID := range c.byLogin[login] {
+		if drop(sessionID) {
+			c.removeElement(c.entries[sessionKey{login: login, sessionID: sessionID}])
+		}
+	}
+}
+
+func (c *CachedAuthStorage) add(key sessionKey, userSecret structs.UserSecret) {
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+
+	el := c.ll.PushFront(&sessionEntry{
+		key:       key,
+		secret:    userSecret,
+		expiresAt: c.now().Add(c.ttl),
+	})
+	c.entries[key] = el
+	if c.byLogin[key.login] == nil {
+		c.byLogin[key.login] = make(map[string]struct{})
+	}
+	c.byLogin[key.login][key.sessionID] = struct{}{}
+
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *CachedAuthStorage) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*sessionEntry)
+	delete(c.entries, entry.key)
+
+	sessions := c.byLogin[entry.key.login]
+	delete(sessions, entry.key.sessionID)
+	if len(sessions) == 0 {
+		delete(c.byLogin, entry.key.login)
+	}
+}
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuthStorage struct {
+	models.AuthStorager
+	secrets map[sessionKey]structs.UserSecret
+	gets    int
+	touches int
+	// Хуки для проверки гонок: вызываются после чтения секрета и перед удалением
+	afterGet     func()
+	beforeDelete func()
+}
+
+func newFakeAuthStorage(secrets ...structs.UserSecret) *fakeAuthStorage {
+	f := &fakeAuthStorage{secrets: make(map[sessionKey]structs.UserSecret)}
+	for _, s := range secrets {
+		f.secrets[sessionKey{login: s.Login, sessionID: s.SessionID}] = s
+	}
+	return f
+}
+
+func (f *fakeAuthStorage) GetUserSecretByLoginAndSession(_ context.Context, lgnSsn structs.UserSecret) (structs.UserSecret, error) {
+	f.gets++
+	s, ok := f.secrets[sessionKey{login: lgnSsn.Login, sessionID: lgnSsn.SessionID}]
+	if f.afterGet != nil {
+		f.afterGet()
+	}
+	if !ok {
+		return structs.UserSecret{}, models.ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeAuthStorage) DeleteUserSecretByLoginAndSession(_ context.Context, lgnSsn structs.UserSecret) error {
+	if f.beforeDelete != nil {
+		f.beforeDelete()
+	}
+	delete(f.secrets, sessionKey{login: lgnSsn.Login, sessionID: lgnSsn.SessionID})
+	return nil
+}
+
+func (f *fakeAuthStorage) DeleteUserSecretsByLogin(_ context.Context, login string) error {
+	for k := range f.secrets {
+		if k.login == login {
+			delete(f.secrets, k)
+		}
+	}
+	return nil
+}
+
+func (f *fakeAuthStorage) DeleteUserSecretsByLoginExcept(_ context.Context, login string, sessionID string) error {
+	for k := range f.secrets {
+		if k.login == login && k.sessionID != sessionID {
+			delete(f.secrets, k)
+		}
+	}
+	return nil
+}
+
+func (f *fakeAuthStorage) RotateRefreshToken(_ context.Context, _ string, _ structs.RefreshToken) (structs.UserSecret, error) {
+	// Считаем, что предъявлен уже использованный токен сессии s1 пользователя user1
+	delete(f.secrets, sessionKey{login: "user1", sessionID: "s1"})
+	return structs.UserSecret{Login: "user1", SessionID: "s1"}, models.ErrRefreshTokenReused
+}
+
+func (f *fakeAuthStorage) TouchSession(_ context.Context, _ structs.UserSecret) error {
+	f.touches++
+	return nil
+}
+
+func sessions() []structs.UserSecret {
+	return []structs.UserSecret{
+		{Login: "user1", SessionID: "s1", Secret: "a"},
+		{Login: "user1", SessionID: "s2", Secret: "b"},
+		{Login: "user2", SessionID: "s3", Secret: "c"},
+	}
+}
+
+func TestCachedAuthStorage_HitMiss(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAuthStorage(sessions()...)
+	c := NewCachedAuthStorage(fake, config.SessionCache{Size: 10, TTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		s, err := c.GetUserSecretByLoginAndSession(ctx, structs.UserSecret{Login: "user1", SessionID: "s1"})
+		require.NoError(t, err)
+		assert.Equal(t, "a", s.Secret)
+	}
+	_, err := c.GetUserSecretByLoginAndSession(ctx, structs.UserSecret{Login: "user1", SessionID: "none"})
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	assert.Equal(t, 2, fake.gets)
+	assert.Equal(t, structs.CacheStats{Hits: 2, Misses: 2, Size: 1}, c.Stats())
+}
+
+func TestCachedAuthStorage_TTLAndEviction(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAuthStorage(sessions()...)
+	c := NewCachedAuthStorage(fake, config.SessionCache{Size: 2, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for _, s := range sessions() {
+		_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+		require.NoError(t, err)
+	}
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+
+	now = now.Add(2 * time.Minute)
+	_, err := c.GetUserSecretByLoginAndSession(ctx, sessions()[2])
+	require.NoError(t, err)
+	assert.Equal(t, 4, fake.gets)
+}
+
+func TestCachedAuthStorage_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	load := func(c *CachedAuthStorage) {
+		for _, s := range sessions() {
+			_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+			require.NoError(t, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		revoke  func(c *CachedAuthStorage) error
+		revoked []int
+	}{
+		{
+			name: "Logout",
+			revoke: func(c *CachedAuthStorage) error {
+				return c.DeleteUserSecretByLoginAndSession(ctx, structs.UserSecret{Login: "user1", SessionID: "s1"})
+			},
+			revoked: []int{0},
+		},
+		{
+			name:    "Logout all",
+			revoke:  func(c *CachedAuthStorage) error { return c.DeleteUserSecretsByLogin(ctx, "user1") },
+			revoked: []int{0, 1},
+		},
+		{
+			name:    "Password change",
+			revoke:  func(c *CachedAuthStorage) error { return c.DeleteUserSecretsByLoginExcept(ctx, "user1", "s2") },
+			revoked: []int{0},
+		},
+		{
+			name: "Refresh token reuse",
+			revoke: func(c *CachedAuthStorage) error {
+				_, err := c.RotateRefreshToken(ctx, "hash", structs.RefreshToken{})
+				assert.ErrorIs(t, err, models.ErrRefreshTokenReused)
+				return nil
+			},
+			revoked: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCachedAuthStorage(newFakeAuthStorage(sessions()...), config.SessionCache{Size: 10, TTL: time.Minute})
+			load(c)
+			require.NoError(t, tt.revoke(c))
+
+			for i, s := range sessions() {
+				_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+				if contains(tt.revoked, i) {
+					assert.ErrorIs(t, err, models.ErrNotFound, s.SessionID)
+				} else {
+					assert.NoError(t, err, s.SessionID)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedAuthStorage_TouchSession(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAuthStorage(sessions()...)
+	c := NewCachedAuthStorage(fake, config.SessionCache{Size: 10, TTL: time.Hour, TouchInterval: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	s := sessions()[0]
+	_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+	require.NoError(t, err)
+
+	require.NoError(t, c.TouchSession(ctx, s))
+	require.NoError(t, c.TouchSession(ctx, s))
+	assert.Equal(t, 1, fake.touches)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, c.TouchSession(ctx, s))
+	assert.Equal(t, 2, fake.touches)
+}
+
+func TestCachedAuthStorage_InvalidateDuringMiss(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAuthStorage(sessions()...)
+	c := NewCachedAuthStorage(fake, config.SessionCache{Size: 10, TTL: time.Minute})
+	s := sessions()[0]
+
+	deleting, proceedDelete := make(chan struct{}), make(chan struct{})
+	read, proceedGet := make(chan struct{}), make(chan struct{})
+	fake.beforeDelete = func() {
+		close(deleting)
+		<-proceedDelete
+	}
+	fake.afterGet = func() {
+		close(read)
+		<-proceedGet
+	}
+
+	deleted := make(chan error)
+	go func() { deleted <- c.DeleteUserSecretByLoginAndSession(ctx, s) }()
+	<-deleting
+
+	// Промах начинается после первой инвалидации и читает секрет до удаления из БД
+	got := make(chan error)
+	go func() {
+		_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+		got <- err
+	}()
+	<-read
+
+	close(proceedDelete)
+	require.NoError(t, <-deleted)
+	close(proceedGet)
+	require.NoError(t, <-got)
+
+	fake.afterGet = nil
+	assert.Equal(t, 0, c.Stats().Size)
+	_, err := c.GetUserSecretByLoginAndSession(ctx, s)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func contains(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
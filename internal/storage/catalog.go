@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/models"
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/storage/repository"
+)
+
+type CatalogRepo interface {
+	ListItemsDB(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error)
+	CreateItemDB(ctx context.Context, change structs.ItemChange) (*structs.Item, error)
+	UpdateItemDB(ctx context.Context, change structs.ItemChange) (*structs.Item, error)
+}
+
+type CatalogStorage struct {
+	catalogRepo CatalogRepo
+}
+
+func NewCatalogStorage(catalogRepo CatalogRepo) CatalogStorage {
+	return CatalogStorage{catalogRepo: catalogRepo}
+}
+
+// ListItemsST items
+func (s *CatalogStorage) ListItemsST(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error) {
+	return s.catalogRepo.ListItemsDB(ctx, filter)
+}
+
+// CreateItemST item
+// Returns models.ErrConflict or err
+func (s *CatalogStorage) CreateItemST(ctx context.Context, change structs.ItemChange) (structs.Item, error) {
+	item, err := s.catalogRepo.CreateItemDB(ctx, change)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return structs.Item{}, models.ErrConflict
+		}
+		return structs.Item{}, err
+	}
+	return *item, nil
+}
+
+// UpdateItemST item
+// Returns models.ErrNoSuchItem or err
+func (s *CatalogStorage) UpdateItemST(ctx context.Context, change structs.ItemChange) (structs.Item, error) {
+	item, err := s.catalogRepo.UpdateItemDB(ctx, change)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoSuchItem) {
+			return structs.Item{}, models.ErrNoSuchItem
+		}
+		return structs.Item{}, err
+	}
+	return *item, nil
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+create schema if not exists shop_schema;
+
+create table if not exists shop_schema.catalog (
+    name      text primary key not null,
+    price     int  not null check (price >= 0),
+    available boolean not null default true
+);
+
+insert into shop_schema.catalog(name, price)
+values ('t-shirt', 80),
+       ('cup', 20),
+       ('book', 50),
+       ('pen', 10),
+       ('powerbank', 200),
+       ('hoody', 300),
+       ('umbrella', 200),
+       ('socks', 10),
+       ('wallet', 50),
+       ('pink-hoody', 500)
+on conflict (name) do nothing;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop schema shop_schema cascade;
+-- +goose StatementEnd
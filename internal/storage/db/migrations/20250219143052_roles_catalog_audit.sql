@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.user_roles (
+    login text not null references users_schema.users(login) on delete cascade on update cascade,
+    role  text not null,
+    primary key (login, role)
+);
+
+create table if not exists shop_schema.catalog_audit (
+    id            serial primary key,
+    item          text        not null,
+    action        text        not null,
+    changed_by    text        not null,
+    old_price     int,
+    new_price     int,
+    old_available boolean,
+    new_available boolean,
+    changed_at    timestamptz not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_audit_item ON shop_schema.catalog_audit (item);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists shop_schema.catalog_audit;
+drop table if exists users_schema.user_roles;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table auth_schema.users_secrets
+    add column if not exists user_agent   text        not null default '',
+    add column if not exists client_ip    text        not null default '',
+    add column if not exists created_at   timestamptz not null default now(),
+    add column if not exists last_seen_at timestamptz not null default now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table auth_schema.users_secrets
+    drop column if exists user_agent,
+    drop column if exists client_ip,
+    drop column if exists created_at,
+    drop column if exists last_seen_at;
+-- +goose StatementEnd
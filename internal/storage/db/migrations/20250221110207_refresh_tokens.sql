@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists auth_schema.refresh_tokens (
+    token_hash text primary key not null,
+    login      text        not null,
+    session_id text        not null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    rotated_at timestamptz,
+    foreign key (login, session_id) references auth_schema.users_secrets (login, session_id)
+        on delete cascade on update cascade
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON auth_schema.refresh_tokens (login, session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists auth_schema.refresh_tokens;
+-- +goose StatementEnd
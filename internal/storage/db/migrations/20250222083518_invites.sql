@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.invites (
+    code_hash  text primary key not null,
+    created_by text references users_schema.users(login) on delete set null on update cascade,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    used_by    text references users_schema.users(login) on delete set null on update cascade,
+    used_at    timestamptz
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists users_schema.invites;
+-- +goose StatementEnd
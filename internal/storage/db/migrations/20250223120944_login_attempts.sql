@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists auth_schema.login_attempts (
+    key             text primary key not null,
+    failures        int         not null default 0,
+    last_failure_at timestamptz not null default now(),
+    locked_until    timestamptz
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists auth_schema.login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.password_resets (
+    token_hash text primary key not null,
+    login      text        not null references users_schema.users(login) on delete cascade on update cascade,
+    created_by text        not null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    used_at    timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_login ON users_schema.password_resets (login);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists users_schema.password_resets;
+-- +goose StatementEnd
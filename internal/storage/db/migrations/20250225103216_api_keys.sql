@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists auth_schema.api_keys (
+    id           uuid primary key not null,
+    key_hash     text unique      not null,
+    login        text             not null references users_schema.users(login) on delete cascade on update cascade,
+    name         text             not null,
+    scopes       text             not null, -- через пробел, как scope в OAuth
+    created_at   timestamptz      not null default now(),
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_login ON auth_schema.api_keys (login);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists auth_schema.api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists auth_schema.totp (
+    login          text primary key not null references users_schema.users(login) on delete cascade on update cascade,
+    secret         text        not null,
+    created_at     timestamptz not null default now(),
+    confirmed_at   timestamptz,
+    last_used_step bigint      not null default 0 -- не даём повторно использовать код
+);
+
+create table if not exists auth_schema.totp_recovery_codes (
+    login     text not null references auth_schema.totp(login) on delete cascade on update cascade,
+    code_hash text not null,
+    used_at   timestamptz,
+    primary key (login, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists auth_schema.totp_recovery_codes;
+drop table if exists auth_schema.totp;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table users_schema.users
+    add column if not exists status text not null default 'active'
+        check (status in ('active', 'deactivated', 'deleted')),
+    add column if not exists deactivated_at timestamptz;
+
+-- Удаление пользователя не должно ломать историю, а переименование при анонимизации - доходить до всех таблиц
+alter table users_schema.account
+    drop constraint if exists account_login_fkey,
+    add constraint account_login_fkey foreign key (login)
+        references users_schema.users(login) on delete cascade on update cascade;
+
+alter table users_schema.user_items
+    drop constraint if exists user_items_login_fkey,
+    add constraint user_items_login_fkey foreign key (login)
+        references users_schema.users(login) on delete cascade on update cascade;
+
+alter table auth_schema.users_secrets
+    drop constraint if exists users_secrets_login_fkey,
+    add constraint users_secrets_login_fkey foreign key (login)
+        references users_schema.users(login) on delete cascade on update cascade;
+
+alter table users_schema.user_operations
+    alter column sender drop not null,
+    alter column recipient drop not null,
+    drop constraint if exists user_operations_sender_fkey,
+    drop constraint if exists user_operations_recipient_fkey,
+    add constraint user_operations_sender_fkey foreign key (sender)
+        references users_schema.users(login) on delete set null on update cascade,
+    add constraint user_operations_recipient_fkey foreign key (recipient)
+        references users_schema.users(login) on delete set null on update cascade;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Операции удалённых пользователей не вернуть под not null, они теряются
+delete from users_schema.user_operations where sender is null or recipient is null;
+
+alter table users_schema.user_operations
+    drop constraint if exists user_operations_sender_fkey,
+    drop constraint if exists user_operations_recipient_fkey,
+    add constraint user_operations_sender_fkey foreign key (sender) references users_schema.users(login),
+    add constraint user_operations_recipient_fkey foreign key (recipient) references users_schema.users(login),
+    alter column sender set not null,
+    alter column recipient set not null;
+
+alter table auth_schema.users_secrets
+    drop constraint if exists users_secrets_login_fkey,
+    add constraint users_secrets_login_fkey foreign key (login) references users_schema.users(login);
+
+alter table users_schema.user_items
+    drop constraint if exists user_items_login_fkey,
+    add constraint user_items_login_fkey foreign key (login) references users_schema.users(login);
+
+alter table users_schema.account
+    drop constraint if exists account_login_fkey,
+    add constraint account_login_fkey foreign key (login) references users_schema.users(login);
+
+alter table users_schema.users
+    drop column if exists deactivated_at,
+    drop column if exists status;
+-- +goose StatementEnd
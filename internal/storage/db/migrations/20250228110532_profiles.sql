@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.profiles (
+    login        text primary key not null references users_schema.users(login) on delete cascade on update cascade,
+    display_name text        not null default '',
+    department   text        not null default '',
+    avatar_url   text        not null default '',
+    bio          text        not null default '',
+    updated_at   timestamptz not null default now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists users_schema.profiles;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+create extension if not exists pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_login_trgm ON users_schema.users USING gin (login gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_profiles_display_name_trgm ON users_schema.profiles USING gin (display_name gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index if exists users_schema.idx_profiles_display_name_trgm;
+drop index if exists users_schema.idx_users_login_trgm;
+-- Расширение могут использовать другие схемы, поэтому не удаляем его
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.ledger (
+    id            uuid primary key not null default gen_random_uuid(),
+    operation_id  uuid        not null,
+    created_at    timestamptz not null default now(),
+    login         text references users_schema.users(login) on delete set null on update cascade,
+    type          text        not null check (type in ('transfer', 'purchase', 'grant', 'refund')),
+    amount        int         not null,
+    counterparty  text references users_schema.users(login) on delete set null on update cascade,
+    item          text,
+    balance_after int         not null
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_login_created ON users_schema.ledger (login, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ledger_operation ON users_schema.ledger (operation_id);
+
+-- Записи леджера не меняются и не удаляются. Разрешено только обнуление и переименование логинов,
+-- которые делают внешние ключи при удалении и анонимизации пользователя
+create or replace function users_schema.ledger_append_only() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    if (new.id, new.operation_id, new.created_at, new.type, new.amount, new.item, new.balance_after)
+        is distinct from (old.id, old.operation_id, old.created_at, old.type, old.amount, old.item, old.balance_after) then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger ledger_append_only
+    before update or delete on users_schema.ledger
+    for each row execute function users_schema.ledger_append_only();
+
+-- Прошлые операции восстановить нельзя, поэтому текущий баланс записывается начальным начислением
+insert into users_schema.ledger (operation_id, login, type, amount, balance_after)
+select gen_random_uuid(), login, 'grant', balance, balance from users_schema.account;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists users_schema.ledger;
+drop function if exists users_schema.ledger_append_only();
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists users_schema.idempotency_keys (
+    login        text        not null references users_schema.users(login) on delete cascade on update cascade,
+    key          text        not null,
+    request_hash text        not null,
+    status_code  int,
+    response     bytea,
+    created_at   timestamptz not null default now(),
+    primary key (login, key)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists users_schema.idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table users_schema.ledger
+    add column if not exists reverses uuid;
+
+-- Операцию можно отменить только один раз
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_reverses ON users_schema.ledger (reverses, login) WHERE reverses IS NOT NULL;
+
+create or replace function users_schema.ledger_append_only() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    if (new.id, new.operation_id, new.created_at, new.type, new.amount, new.item, new.balance_after, new.message, new.tag, new.reverses)
+        is distinct from (old.id, old.operation_id, old.created_at, old.type, old.amount, old.item, old.balance_after, old.message, old.tag, old.reverses) then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    return new;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+create or replace function users_schema.ledger_append_only() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    if (new.id, new.operation_id, new.created_at, new.type, new.amount, new.item, new.balance_after, new.message, new.tag)
+        is distinct from (old.id, old.operation_id, old.created_at, old.type, old.amount, old.item, old.balance_after, old.message, old.tag) then
+        raise exception 'ledger is append-only' using errcode = 'restrict_violation';
+    end if;
+    return new;
+end;
+$$ language plpgsql;
+
+drop index if exists users_schema.idx_ledger_reverses;
+
+alter table users_schema.ledger
+    drop column if exists reverses;
+-- +goose StatementEnd
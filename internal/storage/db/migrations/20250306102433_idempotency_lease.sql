@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Незавершённый запрос держит ключ только до locked_until, после падения сервера ключ можно перехватить
+alter table users_schema.idempotency_keys
+    add column if not exists locked_until timestamptz not null default now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table users_schema.idempotency_keys
+    drop column if exists locked_until;
+-- +goose StatementEnd
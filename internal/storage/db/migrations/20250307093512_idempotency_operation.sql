@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Операция, выполненная по ключу, записывается в той же транзакции, что и леджер:
+-- после падения между коммитом и сохранением ответа повтор не выполнит её снова
+alter table users_schema.idempotency_keys
+    add column if not exists operation_id uuid;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table users_schema.idempotency_keys
+    drop column if exists operation_id;
+-- +goose StatementEnd
@@ -9,3 +9,17 @@ var ErrDuplicateKey = errors.New("duplicate key")
 var ErrNoSuchItem = errors.New("unknown item")
 
 var ErrCheckConstraint = errors.New("violating check constraint")
+
+var ErrTokenReused = errors.New("token already used")
+
+var ErrInvalidInvite = errors.New("invalid invite")
+
+var ErrUserInactive = errors.New("user is not active")
+
+var ErrNotReversible = errors.New("operation can't be reversed")
+
+var ErrCoinsSpent = errors.New("coins are already spent")
+
+var ErrUndoExpired = errors.New("undo window expired")
+
+var ErrIdempotencyKeyUsed = errors.New("idempotency key is already used by another operation")
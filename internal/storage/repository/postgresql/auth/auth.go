@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/storage/db"
@@ -34,8 +36,9 @@ func (r *Repo) CreateUserSecret(ctx context.Context, userSecret *structs.UserSec
 	defer tx.Rollback()
 
 	err = tx.QueryRowContext(ctx,
-		`INSERT INTO auth_schema.users_secrets(login, secret, session_id)
-				VALUES($1, $2, $3) returning login;`, userSecret.Login, userSecret.Secret, userSecret.SessionID).Scan(&tmpLgn)
+		`INSERT INTO auth_schema.users_secrets(login, secret, session_id, user_agent, client_ip)
+				VALUES($1, $2, $3, $4, $5) returning login;`,
+		userSecret.Login, userSecret.Secret, userSecret.SessionID, userSecret.UserAgent, userSecret.ClientIP).Scan(&tmpLgn)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -86,3 +89,576 @@ func (r *Repo) GetSecretByLoginAndSession(ctx context.Context, lgnSsn structs.Us
 
 	return &userSecret, nil
 }
+
+// DeleteSecretByLoginAndSession delete secret, so the session can't be used anymore
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) DeleteSecretByLoginAndSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`DELETE FROM auth_schema.users_secrets WHERE login=$1 and session_id=$2;`, lgnSsn.Login, lgnSsn.SessionID)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteSecretByLoginAndSession", "DELETE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteSecretByLoginAndSession", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// DeleteSecretsByLogin delete all secrets of user
+func (r *Repo) DeleteSecretsByLogin(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM auth_schema.users_secrets WHERE login=$1;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteSecretsByLogin", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
+
+// GetSessionsByLogin get all sessions of user, most recently used first
+func (r *Repo) GetSessionsByLogin(ctx context.Context, login string) ([]structs.Session, error) {
+	lgr := logger.GetLogger()
+
+	sessions := []structs.Session{}
+
+	err := r.db.Select(ctx, &sessions,
+		`SELECT session_id, user_agent, client_ip, created_at, last_seen_at FROM auth_schema.users_secrets
+				WHERE login=$1 ORDER BY last_seen_at DESC;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "GetSessionsByLogin", "SELECT")
+
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession update last seen time of session.
+// Updated at most once a minute to not write on every request.
+func (r *Repo) TouchSession(ctx context.Context, lgnSsn structs.UserSecret) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.users_secrets SET last_seen_at = now()
+				WHERE login=$1 and session_id=$2 and last_seen_at < now() - interval '1 minute';`, lgnSsn.Login, lgnSsn.SessionID)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "TouchSession", "UPDATE")
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSecretsByLoginExcept delete all secrets of user but the given session
+func (r *Repo) DeleteSecretsByLoginExcept(ctx context.Context, login string, sessionID string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM auth_schema.users_secrets WHERE login=$1 and session_id<>$2;`, login, sessionID)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteSecretsByLoginExcept", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
+
+// CreateRefreshToken create refresh token of session
+func (r *Repo) CreateRefreshToken(ctx context.Context, token *structs.RefreshToken) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO auth_schema.refresh_tokens(token_hash, login, session_id, expires_at)
+				VALUES($1, $2, $3, $4);`, token.TokenHash, token.Login, token.SessionID, token.ExpiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repository.ErrDuplicateKey
+		}
+		lgr.Error(err.Error(), "Repo", "CreateRefreshToken", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+// RotateRefreshToken marks refresh token as rotated and creates the next one in the same session.
+// If the token was already rotated, the session is deleted together with all its refresh tokens.
+// Returns repository.ErrObjectNotFound, repository.ErrTokenReused or err
+func (r *Repo) RotateRefreshToken(ctx context.Context, oldHash string, newToken *structs.RefreshToken) (*structs.UserSecret, error) {
+	lgr := logger.GetLogger()
+
+	oldToken := struct {
+		structs.RefreshToken
+		RotatedAt *time.Time `db:"rotated_at"`
+	}{}
+	userSecret := structs.UserSecret{}
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &oldToken,
+		`SELECT token_hash, login, session_id, expires_at, rotated_at FROM auth_schema.refresh_tokens
+				WHERE token_hash=$1 FOR UPDATE;`, oldHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "SELECT1")
+
+		return nil, err
+	}
+
+	if oldToken.RotatedAt != nil {
+		// Токен уже меняли, значит его украли. Отзываем всю сессию.
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM auth_schema.users_secrets WHERE login=$1 and session_id=$2;`, oldToken.Login, oldToken.SessionID)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "DELETE")
+
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "reuse-Commit")
+
+			return nil, err
+		}
+
+		return &structs.UserSecret{Login: oldToken.Login, SessionID: oldToken.SessionID}, repository.ErrTokenReused
+	}
+
+	if oldToken.ExpiresAt.Before(time.Now()) {
+		return nil, repository.ErrObjectNotFound
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE auth_schema.refresh_tokens SET rotated_at = now() WHERE token_hash=$1;`, oldHash)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "UPDATE")
+
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO auth_schema.refresh_tokens(token_hash, login, session_id, expires_at)
+				VALUES($1, $2, $3, $4);`, newToken.TokenHash, oldToken.Login, oldToken.SessionID, newToken.ExpiresAt)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "INSERT")
+
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &userSecret,
+		`SELECT login, secret, session_id FROM auth_schema.users_secrets WHERE login=$1 and session_id=$2;`,
+		oldToken.Login, oldToken.SessionID)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "SELECT2")
+
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "RotateRefreshToken", "Commit")
+
+		return nil, err
+	}
+
+	return &userSecret, nil
+}
+
+// GetLockedUntil get time until key is locked, zero time if it is not
+func (r *Repo) GetLockedUntil(ctx context.Context, key string) (time.Time, error) {
+	lgr := logger.GetLogger()
+
+	var lockedUntil *time.Time
+
+	err := r.db.Get(ctx, &lockedUntil,
+		`SELECT locked_until FROM auth_schema.login_attempts WHERE key=$1;`, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		lgr.Error(err.Error(), "Repo", "GetLockedUntil", "SELECT")
+
+		return time.Time{}, err
+	}
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+
+	return *lockedUntil, nil
+}
+
+// RegisterFailedAttempt count failed attempt of key. Counter starts over if last failure is older than window.
+// Returns number of failures in a row
+func (r *Repo) RegisterFailedAttempt(ctx context.Context, key string, window time.Duration) (int, error) {
+	lgr := logger.GetLogger()
+
+	failures := 0
+
+	err := r.db.Get(ctx, &failures,
+		`INSERT INTO auth_schema.login_attempts(key, failures, last_failure_at)
+				VALUES($1, 1, now())
+				ON CONFLICT (key) DO UPDATE SET
+					failures = CASE
+						WHEN $2::float8 > 0 AND login_attempts.last_failure_at < now() - make_interval(secs => $2::float8) THEN 1
+						ELSE login_attempts.failures + 1
+					END,
+					last_failure_at = now()
+				returning failures;`, key, window.Seconds())
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RegisterFailedAttempt", "INSERT")
+
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// LockAttempts lock key until given time
+func (r *Repo) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.login_attempts SET locked_until = $2 WHERE key=$1;`, key, until)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "LockAttempts", "UPDATE")
+
+		return err
+	}
+
+	return nil
+}
+
+// ResetAttempts forget failed attempts of key
+func (r *Repo) ResetAttempts(ctx context.Context, key string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM auth_schema.login_attempts WHERE key=$1;`, key)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ResetAttempts", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
+
+// apiKeyRow keeps scopes as they are stored
+type apiKeyRow struct {
+	structs.APIKey
+	Scopes string `db:"scopes"`
+}
+
+func (row apiKeyRow) toAPIKey() structs.APIKey {
+	apiKey := row.APIKey
+	apiKey.Scopes = strings.Fields(row.Scopes)
+
+	return apiKey
+}
+
+// CreateAPIKey create api key
+func (r *Repo) CreateAPIKey(ctx context.Context, apiKey *structs.APIKey) error {
+	lgr := logger.GetLogger()
+
+	err := r.db.Get(ctx, &apiKey.CreatedAt,
+		`INSERT INTO auth_schema.api_keys(id, key_hash, login, name, scopes, expires_at)
+				VALUES($1, $2, $3, $4, $5, $6) RETURNING created_at;`,
+		apiKey.ID, apiKey.KeyHash, apiKey.Login, apiKey.Name, strings.Join(apiKey.Scopes, " "), apiKey.ExpiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repository.ErrDuplicateKey
+		}
+		lgr.Error(err.Error(), "Repo", "CreateAPIKey", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+// GetAPIKeysByLogin get active api keys of user, newest first
+func (r *Repo) GetAPIKeysByLogin(ctx context.Context, login string) ([]structs.APIKey, error) {
+	lgr := logger.GetLogger()
+
+	rows := []apiKeyRow{}
+
+	err := r.db.Select(ctx, &rows,
+		`SELECT id, login, name, scopes, created_at, expires_at, last_used_at FROM auth_schema.api_keys
+				WHERE login=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+				ORDER BY created_at DESC;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "GetAPIKeysByLogin", "SELECT")
+
+		return nil, err
+	}
+
+	apiKeys := make([]structs.APIKey, 0, len(rows))
+	for _, row := range rows {
+		apiKeys = append(apiKeys, row.toAPIKey())
+	}
+
+	return apiKeys, nil
+}
+
+// GetAPIKeyByHash get active api key by its hash
+func (r *Repo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*structs.APIKey, error) {
+	lgr := logger.GetLogger()
+
+	row := apiKeyRow{}
+
+	// Ключи деактивированных пользователей не работают, но и не отзываются, чтобы вернуться при активации
+	err := r.db.Get(ctx, &row,
+		`SELECT k.id, k.login, k.name, k.scopes, k.created_at, k.expires_at, k.last_used_at
+				FROM auth_schema.api_keys k JOIN users_schema.users u ON u.login = k.login
+				WHERE k.key_hash=$1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > now())
+				AND u.status = 'active';`, keyHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "GetAPIKeyByHash", "SELECT")
+
+		return nil, err
+	}
+
+	apiKey := row.toAPIKey()
+
+	return &apiKey, nil
+}
+
+// RevokeAPIKey mark api key of user as revoked. Row is kept for history.
+func (r *Repo) RevokeAPIKey(ctx context.Context, login string, id string) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.api_keys SET revoked_at = now()
+				WHERE id=$1 AND login=$2 AND revoked_at IS NULL;`, id, login)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // не uuid
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "RevokeAPIKey", "UPDATE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RevokeAPIKey", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey update last used time of api key.
+// Updated at most once a minute to not write on every request.
+func (r *Repo) TouchAPIKey(ctx context.Context, id string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.api_keys SET last_used_at = now()
+				WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');`, id)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "TouchAPIKey", "UPDATE")
+
+		return err
+	}
+
+	return nil
+}
+
+// UpsertPendingTOTP save new not yet confirmed totp secret of user.
+// Confirmed secret isn't replaced.
+func (r *Repo) UpsertPendingTOTP(ctx context.Context, login string, secret string) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`INSERT INTO auth_schema.totp(login, secret) VALUES($1, $2)
+				ON CONFLICT (login) DO UPDATE SET secret = excluded.secret, created_at = now(), last_used_step = 0
+				WHERE auth_schema.totp.confirmed_at IS NULL;`, login, secret)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "UpsertPendingTOTP", "INSERT")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpsertPendingTOTP", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrDuplicateKey
+	}
+
+	return nil
+}
+
+// GetTOTP get totp secret of user
+func (r *Repo) GetTOTP(ctx context.Context, login string) (*structs.TOTP, error) {
+	lgr := logger.GetLogger()
+
+	totp := structs.TOTP{}
+
+	err := r.db.Get(ctx, &totp,
+		`SELECT login, secret, confirmed_at, last_used_step FROM auth_schema.totp WHERE login=$1;`, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "GetTOTP", "SELECT")
+
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// ConfirmTOTP mark totp of user confirmed and save hashes of recovery codes
+func (r *Repo) ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error {
+	lgr := logger.GetLogger()
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE auth_schema.totp SET confirmed_at = now(), last_used_step = $2
+				WHERE login=$1 AND confirmed_at IS NULL AND last_used_step < $2;`, login, step)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ConfirmTOTP", "UPDATE")
+
+		return err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ConfirmTOTP", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	for _, hash := range recoveryHashes {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO auth_schema.totp_recovery_codes(login, code_hash) VALUES($1, $2);`, login, hash)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "ConfirmTOTP", "INSERT")
+
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "ConfirmTOTP", "Commit")
+
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep remember used step of confirmed totp. The same or earlier step is rejected.
+func (r *Repo) UseTOTPStep(ctx context.Context, login string, step int64) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.totp SET last_used_step = $2
+				WHERE login=$1 AND confirmed_at IS NOT NULL AND last_used_step < $2;`, login, step)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UseTOTPStep", "UPDATE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UseTOTPStep", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode mark unused recovery code of user as used
+func (r *Repo) UseRecoveryCode(ctx context.Context, login string, codeHash string) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`UPDATE auth_schema.totp_recovery_codes SET used_at = now()
+				WHERE login=$1 AND code_hash=$2 AND used_at IS NULL;`, login, codeHash)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UseRecoveryCode", "UPDATE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UseRecoveryCode", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// DeleteTOTP delete totp of user with its recovery codes
+func (r *Repo) DeleteTOTP(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx, `DELETE FROM auth_schema.totp WHERE login=$1;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteTOTP", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
@@ -229,6 +229,81 @@ func TestRepo_GetSecretByLoginAndSession(t *testing.T) {
 	}
 }
 
+func TestRepo_DeleteSecretByLoginAndSession(t *testing.T) {
+	type fields struct {
+		db db.DBops
+	}
+	type args struct {
+		ctx    context.Context
+		lgnSsn structs.UserSecret
+	}
+
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Error("NewPostgresStorage: " + err.Error())
+	}
+
+	tmpKey, _ := genInt(64)
+	tmpUUID, _ := uuid.NewV4()
+	r := &Repo{db: dbStor.DB}
+	err = r.CreateUserSecret(ctx, &structs.UserSecret{
+		Login:     "user1user2",
+		Secret:    tmpKey,
+		SessionID: tmpUUID.String(),
+	})
+	if err != nil {
+		t.Error("CreateUserSecret: " + err.Error())
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name:   "Existing session",
+			fields: fields{dbStor.DB},
+			args: args{
+				ctx: ctx,
+				lgnSsn: structs.UserSecret{
+					Login:     "user1user2",
+					SessionID: tmpUUID.String(),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "Already deleted session",
+			fields: fields{dbStor.DB},
+			args: args{
+				ctx: ctx,
+				lgnSsn: structs.UserSecret{
+					Login:     "user1user2",
+					SessionID: tmpUUID.String(),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repo{
+				db: tt.fields.db,
+			}
+			if err := r.DeleteSecretByLoginAndSession(tt.args.ctx, tt.args.lgnSsn); (err != nil) != tt.wantErr {
+				t.Errorf("DeleteSecretByLoginAndSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func genInt(length int) (string, error) {
 	result := ""
 	for {
@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Kapeland/task-Avito/internal/models/structs"
+	"github.com/Kapeland/task-Avito/internal/storage/db"
+	"github.com/Kapeland/task-Avito/internal/storage/repository"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+// GetItemPriceTx get price of available item inside the given transaction.
+// Row is locked until the end of tx, so the price can't change during purchase.
+// Returns repository.ErrNoSuchItem or err
+func (r *Repo) GetItemPriceTx(ctx context.Context, tx *sqlx.Tx, item string) (int, error) {
+	lgr := logger.GetLogger()
+
+	price := 0
+
+	err := tx.GetContext(ctx, &price,
+		`SELECT price FROM shop_schema.catalog WHERE name=$1 AND available FOR SHARE;`, item)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return 0, repository.ErrNoSuchItem
+		}
+
+		lgr.Error(err.Error(), "Repo", "GetItemPriceTx", "SELECT")
+
+		return 0, err
+	}
+
+	return price, nil
+}
+
+// ListItemsDB list catalog items, retired ones included
+func (r *Repo) ListItemsDB(ctx context.Context, filter structs.ItemsFilter) ([]structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	items := []structs.Item{}
+
+	query := `SELECT name, price, available FROM shop_schema.catalog WHERE ($1::int IS NULL OR price <= $1)`
+
+	switch filter.Sort {
+	case structs.ItemsSortByPriceAsc:
+		query += ` ORDER BY price ASC, name;`
+	case structs.ItemsSortByPriceDesc:
+		query += ` ORDER BY price DESC, name;`
+	default:
+		query += ` ORDER BY name;`
+	}
+
+	err := r.db.Select(ctx, &items, query, filter.MaxPrice)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ListItemsDB", "SELECT")
+
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// CreateItemDB create catalog item and record it in audit
+// Returns repository.ErrDuplicateKey or err
+func (r *Repo) CreateItemDB(ctx context.Context, change structs.ItemChange) (*structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	item := structs.Item{}
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &item,
+		`INSERT INTO shop_schema.catalog(name, price, available)
+				VALUES($1, $2, COALESCE($3, true)) returning name, price, available;`,
+		change.Name, change.Price, change.Available)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, repository.ErrDuplicateKey
+		}
+		lgr.Error(err.Error(), "Repo", "CreateItemDB", "INSERT1")
+
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shop_schema.catalog_audit(item, action, changed_by, new_price, new_available)
+				VALUES($1, $2, $3, $4, $5);`,
+		item.Name, change.Action, change.ChangedBy, item.Price, item.Available)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateItemDB", "INSERT2")
+
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateItemDB", "Commit")
+
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// UpdateItemDB change price and/or availability of catalog item and record it in audit
+// Returns repository.ErrNoSuchItem or err
+func (r *Repo) UpdateItemDB(ctx context.Context, change structs.ItemChange) (*structs.Item, error) {
+	lgr := logger.GetLogger()
+
+	oldItem := structs.Item{}
+	newItem := structs.Item{}
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &oldItem,
+		`SELECT name, price, available FROM shop_schema.catalog WHERE name=$1 FOR UPDATE;`, change.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrNoSuchItem
+		}
+		lgr.Error(err.Error(), "Repo", "UpdateItemDB", "SELECT")
+
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &newItem,
+		`UPDATE shop_schema.catalog SET price = COALESCE($2, price), available = COALESCE($3, available)
+				WHERE name = $1 returning name, price, available;`,
+		change.Name, change.Price, change.Available)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdateItemDB", "UPDATE")
+
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shop_schema.catalog_audit(item, action, changed_by, old_price, new_price, old_available, new_available)
+				VALUES($1, $2, $3, $4, $5, $6, $7);`,
+		newItem.Name, change.Action, change.ChangedBy, oldItem.Price, newItem.Price, oldItem.Available, newItem.Available)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdateItemDB", "INSERT")
+
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdateItemDB", "Commit")
+
+		return nil, err
+	}
+
+	return &newItem, nil
+}
@@ -0,0 +1,115 @@
+package catalog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/storage"
+	"github.com/Kapeland/task-Avito/internal/storage/db"
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/Kapeland/task-Avito/internal/utils/logger"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		db db.DBops
+	}
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Error("NewPostgresStorage: " + err.Error())
+	}
+	tests := []struct {
+		name string
+		args args
+		want *Repo
+	}{
+		{
+			name: "Init DB",
+			args: args{db: dbStor.DB},
+			want: &Repo{db: dbStor.DB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.args.db); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepo_GetItemPriceTx(t *testing.T) {
+	type fields struct {
+		db db.DBops
+	}
+	type args struct {
+		ctx  context.Context
+		item string
+	}
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Error("NewPostgresStorage: " + err.Error())
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    int
+		wantErr bool
+	}{
+		{
+			name:   "Existing item",
+			fields: fields{db: dbStor.DB},
+			args: args{
+				ctx:  ctx,
+				item: "pen",
+			},
+			want:    10,
+			wantErr: false,
+		},
+		{
+			name:   "Not existing item",
+			fields: fields{db: dbStor.DB},
+			args: args{
+				ctx:  ctx,
+				item: "red-hoody",
+			},
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repo{
+				db: tt.fields.db,
+			}
+			tx, err := r.db.(*db.PgDatabase).BeginX(tt.args.ctx, nil)
+			if err != nil {
+				t.Fatal("BeginX: " + err.Error())
+			}
+			defer tx.Rollback()
+
+			got, err := r.GetItemPriceTx(tt.args.ctx, tx, tt.args.item)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItemPriceTx() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetItemPriceTx() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,29 +4,50 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/storage/db"
 	"github.com/Kapeland/task-Avito/internal/storage/repository"
+	"github.com/Kapeland/task-Avito/internal/storage/repository/postgresql/catalog"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/Kapeland/task-Avito/internal/utils/passhash"
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
 )
 
 type Repo struct {
-	db db.DBops
+	db      db.DBops
+	catalog *catalog.Repo
+	hasher  passhash.Hasher
+
+	// dummyHash проверяется для неизвестных логинов, чтобы время ответа не выдавало существование пользователя
+	dummyOnce sync.Once
+	dummyHash string
 }
 
-func New(db db.DBops) *Repo {
-	return &Repo{db: db}
+func New(db db.DBops, catalog *catalog.Repo, hasher passhash.Hasher) *Repo {
+	return &Repo{db: db, catalog: catalog, hasher: hasher}
 }
 
-// CreateUserDB create user
-func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo) error {
+// CreateUserDB create user. Non-empty inviteHash is spent in the same transaction.
+// Returns repository.ErrDuplicateKey, repository.ErrInvalidInvite or err
+func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo, inviteHash string) error {
 	lgr := logger.GetLogger()
 
 	id := 0
 
+	// Хэшируем до начала транзакции, чтобы не держать её открытой на время вычисления
+	pswdHash, err := r.hasher.Hash(info.Pswd)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateUserDB", "Hash")
+		return err
+	}
+
 	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
 	if err != nil {
 		return err
@@ -35,7 +56,7 @@ func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo)
 
 	err = tx.QueryRowContext(ctx,
 		`INSERT INTO users_schema.users(login, password_hash)
-				VALUES($1, crypt($2, gen_salt('bf'))) returning id;`, info.Login, info.Pswd).Scan(&id)
+				VALUES($1, $2) returning id;`, info.Login, pswdHash).Scan(&id)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -47,9 +68,10 @@ func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo)
 		return err
 	}
 	tmp := ""
+	balance := 0
 	err = tx.QueryRowContext(ctx,
 		`INSERT INTO users_schema.account(login)
-				VALUES($1) returning login;`, info.Login).Scan(&tmp)
+				VALUES($1) returning balance;`, info.Login).Scan(&balance)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -62,6 +84,31 @@ func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo)
 		return err
 	}
 
+	// Стартовый баланс тоже проходит через леджер, чтобы сумма записей сходилась с балансом
+	_, err = insertLedgerTx(ctx, tx, structs.LedgerEntry{
+		Login: &info.Login, Type: structs.LedgerTypeGrant, Amount: balance, BalanceAfter: balance,
+	})
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateUserDB", "insertLedgerTx")
+
+		return err
+	}
+
+	if inviteHash != "" {
+		err = tx.QueryRowContext(ctx,
+			`UPDATE users_schema.invites SET used_by = $1, used_at = now()
+					WHERE code_hash = $2 AND used_at IS NULL AND expires_at > now() returning used_by;`,
+			info.Login, inviteHash).Scan(&tmp)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+				return repository.ErrInvalidInvite
+			}
+			lgr.Error(err.Error(), "Repo", "CreateUserDB", "UPDATE")
+
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		lgr.Error(err.Error(), "Repo", "CreateUserDB", "Commit")
 		return err
@@ -71,45 +118,185 @@ func (r *Repo) CreateUserDB(ctx context.Context, info structs.RegisterUserInfo)
 }
 
 // VerifyPasswordDB checks whether the password is correct or no.
+// Hash made by other algorithm or with outdated parameters is replaced on success.
 func (r *Repo) VerifyPasswordDB(ctx context.Context, info structs.AuthUserInfo) (bool, error) {
 	lgr := logger.GetLogger()
 
-	isValid := false
+	pswdHash := ""
 
-	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	// Деактивированные и удалённые пользователи войти не могут
+	err := r.db.Get(ctx, &pswdHash,
+		`SELECT password_hash FROM users_schema.users WHERE login = $1 AND status = 'active';`, info.Login)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			r.verifyDummy(info.Pswd)
+			return false, nil
+		}
+		lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "SELECT")
+
 		return false, err
 	}
-	defer tx.Rollback()
 
-	err = tx.QueryRowContext(ctx,
-		`SELECT (password_hash = crypt($1, password_hash)) 
-    			AS password_match 
-				FROM users_schema.users
-				WHERE login = $2 ;`, info.Pswd, info.Login).Scan(&isValid)
+	isValid, err := r.hasher.Verify(pswdHash, info.Pswd)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "Verify")
 
-	switch {
-	case err != nil && (errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows)):
-		if err := tx.Commit(); err != nil {
-			lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "Commit")
+		return false, err
+	}
+	if !isValid {
+		return false, nil
+	}
 
-			return false, err
+	if r.hasher.NeedsRehash(pswdHash) {
+		// Вход уже удался, неудачная замена хэша не должна ему мешать
+		if err := r.rehashPassword(ctx, info, pswdHash); err != nil {
+			lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "rehashPassword")
 		}
-		return false, nil
-	case err != nil:
-		lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "SELECT")
-		return false, err
-	default:
-		if err := tx.Commit(); err != nil {
-			lgr.Error(err.Error(), "Repo", "VerifyPasswordDB", "def-Commit")
-			return false, err
+	}
+
+	return true, nil
+}
+
+// verifyDummy takes as long as verification of real password hashed by configured algorithm.
+// Result is ignored, login is failed anyway.
+func (r *Repo) verifyDummy(pswd string) {
+	lgr := logger.GetLogger()
+
+	r.dummyOnce.Do(func() {
+		hash, err := r.hasher.Hash("dummy password")
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "verifyDummy", "Hash")
+		}
+		r.dummyHash = hash
+	})
+
+	_, _ = r.hasher.Verify(r.dummyHash, pswd)
+}
+
+// rehashPassword replaces old hash of just verified password.
+// Hash is replaced only if it's still the same, so concurrent password change isn't overwritten.
+func (r *Repo) rehashPassword(ctx context.Context, info structs.AuthUserInfo, oldHash string) error {
+	newHash, err := r.hasher.Hash(info.Pswd)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx,
+		`UPDATE users_schema.users SET password_hash = $1 WHERE login = $2 AND password_hash = $3;`,
+		newHash, info.Login, oldHash)
+
+	return err
+}
+
+// UpdatePasswordDB set new password of user
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) UpdatePasswordDB(ctx context.Context, info structs.AuthUserInfo) error {
+	lgr := logger.GetLogger()
+
+	pswdHash, err := r.hasher.Hash(info.Pswd)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdatePasswordDB", "Hash")
+
+		return err
+	}
+
+	res, err := r.db.Exec(ctx,
+		`UPDATE users_schema.users SET password_hash = $1 WHERE login = $2;`, pswdHash, info.Login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdatePasswordDB", "UPDATE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "UpdatePasswordDB", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// CreatePasswordResetDB create password reset token
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) CreatePasswordResetDB(ctx context.Context, reset *structs.PasswordReset) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO users_schema.password_resets(token_hash, login, created_by, expires_at)
+				VALUES($1, $2, $3, $4);`, reset.TokenHash, reset.Login, reset.CreatedBy, reset.ExpiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, нет такого пользователя
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "CreatePasswordResetDB", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+// RedeemPasswordResetDB spend password reset token and set new password in one transaction
+// Returns login of user, repository.ErrObjectNotFound or err
+func (r *Repo) RedeemPasswordResetDB(ctx context.Context, tokenHash string, newPswd string) (string, error) {
+	lgr := logger.GetLogger()
+
+	login := ""
+
+	pswdHash, err := r.hasher.Hash(newPswd)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RedeemPasswordResetDB", "Hash")
+
+		return "", err
+	}
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE users_schema.password_resets SET used_at = now()
+				WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() returning login;`, tokenHash).Scan(&login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return "", repository.ErrObjectNotFound
 		}
-		return isValid, nil
+		lgr.Error(err.Error(), "Repo", "RedeemPasswordResetDB", "UPDATE1")
+
+		return "", err
 	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE users_schema.users SET password_hash = $1 WHERE login = $2;`, pswdHash, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RedeemPasswordResetDB", "UPDATE2")
+
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "RedeemPasswordResetDB", "Commit")
+
+		return "", err
+	}
+
+	return login, nil
 }
 
-// SendCoinDB send coin to user
-func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) error {
+// SendCoinDB send coin to user. Non-nil checkLimits gets coins already sent by sender after his account is locked,
+// so concurrent transfers of one sender can't exceed limits together. Its error rolls transfer back and is returned as is.
+// Returns repository.ErrObjectNotFound, repository.ErrUserInactive, repository.ErrCheckConstraint,
+// repository.ErrIdempotencyKeyUsed, error of checkLimits or err
+func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo,
+	checkLimits func(structs.TransferStats) error) error {
 	lgr := logger.GetLogger()
 
 	tmp := ""
@@ -120,10 +307,35 @@ func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) e
 	}
 	defer tx.Rollback()
 
+	// Проверили получателя. FOR SHARE не даёт деактивировать его до конца перевода
+	status := ""
+	err = tx.GetContext(ctx, &status,
+		`SELECT status FROM users_schema.users WHERE login = $1 FOR SHARE;`, operation.To)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "SendCoinDB", "SELECT")
+
+		return err
+	}
+	if status != "active" {
+		return repository.ErrUserInactive
+	}
+
+	// Счета блокируем заранее в фиксированном порядке, иначе встречные переводы и отмены могут взаимно заблокироваться
+	_, err = lockAccountsTx(ctx, tx, operation.From, operation.To)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SendCoinDB", "LOCK")
+
+		return err
+	}
+
 	// Вычли у отправителя
+	senderBalance := 0
 	err = tx.QueryRowContext(ctx,
 		`UPDATE users_schema.account SET balance = balance- $1
-				WHERE login = $2 returning login;`, operation.Amount, operation.From).Scan(&tmp)
+				WHERE login = $2 returning balance;`, operation.Amount, operation.From).Scan(&senderBalance)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
@@ -144,10 +356,25 @@ func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) e
 		return err
 	}
 
+	// Строка счёта отправителя уже заблокирована UPDATE, параллельные переводы ждут коммита,
+	// а текущий перевод ещё не записан в леджер
+	if checkLimits != nil {
+		stats, err := getTransferStatsTx(ctx, tx, operation.From, operation.To)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "SendCoinDB", "getTransferStatsTx")
+
+			return err
+		}
+		if err := checkLimits(stats); err != nil {
+			return err
+		}
+	}
+
 	// Добавили получателю
+	recipientBalance := 0
 	err = tx.QueryRowContext(ctx,
 		`UPDATE users_schema.account SET balance = balance + $1
-				WHERE login = $2 returning login;`, operation.Amount, operation.To).Scan(&tmp)
+				WHERE login = $2 returning balance;`, operation.Amount, operation.To).Scan(&recipientBalance)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
@@ -168,6 +395,31 @@ func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) e
 		return err
 	}
 
+	// Сообщение и тег видны обеим сторонам перевода
+	message, tag := nullIfEmpty(operation.Message), nullIfEmpty(operation.Tag)
+	operationID, err := insertLedgerTx(ctx, tx,
+		structs.LedgerEntry{
+			Login: &operation.From, Type: structs.LedgerTypeTransfer, Amount: -operation.Amount,
+			Counterparty: &operation.To, BalanceAfter: senderBalance, Message: message, Tag: tag,
+		},
+		structs.LedgerEntry{
+			Login: &operation.To, Type: structs.LedgerTypeTransfer, Amount: operation.Amount,
+			Counterparty: &operation.From, BalanceAfter: recipientBalance, Message: message, Tag: tag,
+		})
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SendCoinDB", "insertLedgerTx")
+
+		return err
+	}
+
+	if err := bindIdempotencyKeyTx(ctx, tx, operation.From, operation.IdempotencyKey, operationID); err != nil {
+		if !errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+			lgr.Error(err.Error(), "Repo", "SendCoinDB", "bindIdempotencyKeyTx")
+		}
+
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		lgr.Error(err.Error(), "Repo", "SendCoinDB", "Commit")
 
@@ -177,16 +429,11 @@ func (r *Repo) SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) e
 	return nil
 }
 
-// BuyItemDB buy item
-func (r *Repo) BuyItemDB(ctx context.Context, item string, login string) error {
+// BuyItemDB buy item. Non-empty idempotencyKey is marked as done in the same transaction.
+// Returns repository.ErrIdempotencyKeyUsed or err
+func (r *Repo) BuyItemDB(ctx context.Context, item string, login string, idempotencyKey string) error {
 	lgr := logger.GetLogger()
 
-	_, ok := items[item]
-
-	if !ok {
-		return repository.ErrNoSuchItem
-	}
-
 	tmp := ""
 
 	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
@@ -195,9 +442,15 @@ func (r *Repo) BuyItemDB(ctx context.Context, item string, login string) error {
 	}
 	defer tx.Rollback()
 
+	price, err := r.catalog.GetItemPriceTx(ctx, tx, item)
+	if err != nil {
+		return err
+	}
+
+	balance := 0
 	err = tx.QueryRowContext(ctx,
 		`UPDATE users_schema.account SET balance = balance- $1
-				WHERE login = $2 returning login;`, items[item], login).Scan(&tmp)
+				WHERE login = $2 returning balance;`, price, login).Scan(&balance)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
@@ -221,6 +474,23 @@ func (r *Repo) BuyItemDB(ctx context.Context, item string, login string) error {
 		}
 	}
 
+	operationID, err := insertLedgerTx(ctx, tx, structs.LedgerEntry{
+		Login: &login, Type: structs.LedgerTypePurchase, Amount: -price, Item: &item, BalanceAfter: balance,
+	})
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "BuyItemDB", "insertLedgerTx")
+
+		return err
+	}
+
+	if err := bindIdempotencyKeyTx(ctx, tx, login, idempotencyKey, operationID); err != nil {
+		if !errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+			lgr.Error(err.Error(), "Repo", "BuyItemDB", "bindIdempotencyKeyTx")
+		}
+
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		lgr.Error(err.Error(), "Repo", "BuyItemDB", "Commit")
 		return err
@@ -261,8 +531,14 @@ func (r *Repo) GetInfoDB(ctx context.Context, login string) (*structs.AccInfo, e
 		return &structs.AccInfo{}, err
 	}
 
+	// Удалённые пользователи показываются одной строкой вместо своих логинов
 	err = tx.SelectContext(ctx, &accInfo.CoinHistory.Received,
-		`SELECT sender, SUM(amount) AS amount FROM users_schema.user_operations WHERE recipient=$1 GROUP BY sender;`, login)
+		`SELECT CASE WHEN u.status <> 'deleted' THEN o.sender ELSE $2 END AS sender,
+				COALESCE(p.display_name, '') AS sender_name, SUM(o.amount) AS amount
+				FROM users_schema.user_operations o
+				LEFT JOIN users_schema.users u ON u.login = o.sender
+				LEFT JOIN users_schema.profiles p ON p.login = o.sender AND u.status <> 'deleted'
+				WHERE o.recipient=$1 GROUP BY 1, 2 HAVING SUM(o.amount) <> 0;`, login, structs.DeletedUserName)
 	if err != nil {
 		lgr.Error(err.Error(), "Repo", "GetInfoDB", "SELECT3")
 
@@ -270,7 +546,12 @@ func (r *Repo) GetInfoDB(ctx context.Context, login string) (*structs.AccInfo, e
 	}
 
 	err = tx.SelectContext(ctx, &accInfo.CoinHistory.Sent,
-		`SELECT recipient, SUM(amount) AS amount FROM users_schema.user_operations WHERE sender=$1 GROUP BY recipient;`, login)
+		`SELECT CASE WHEN u.status <> 'deleted' THEN o.recipient ELSE $2 END AS recipient,
+				COALESCE(p.display_name, '') AS recipient_name, SUM(o.amount) AS amount
+				FROM users_schema.user_operations o
+				LEFT JOIN users_schema.users u ON u.login = o.recipient
+				LEFT JOIN users_schema.profiles p ON p.login = o.recipient AND u.status <> 'deleted'
+				WHERE o.sender=$1 GROUP BY 1, 2 HAVING SUM(o.amount) <> 0;`, login, structs.DeletedUserName)
 	if err != nil {
 		lgr.Error(err.Error(), "Repo", "GetInfoDB", "SELECT4")
 
@@ -285,3 +566,675 @@ func (r *Repo) GetInfoDB(ctx context.Context, login string) (*structs.AccInfo, e
 
 	return &accInfo, nil
 }
+
+// GetRolesDB get roles granted to user
+func (r *Repo) GetRolesDB(ctx context.Context, login string) ([]string, error) {
+	lgr := logger.GetLogger()
+
+	roles := []string{}
+
+	err := r.db.Select(ctx, &roles,
+		`SELECT role FROM users_schema.user_roles WHERE login=$1 ORDER BY role;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "GetRolesDB", "SELECT")
+
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GrantRoleDB grant role to user, granting existing role is not an error
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) GrantRoleDB(ctx context.Context, login string, role string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO users_schema.user_roles(login, role)
+				VALUES($1, $2) ON CONFLICT DO NOTHING;`, login, role)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, нет такого пользователя
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "GrantRoleDB", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+// RevokeRoleDB revoke role from user, revoking absent role is not an error
+func (r *Repo) RevokeRoleDB(ctx context.Context, login string, role string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM users_schema.user_roles WHERE login=$1 AND role=$2;`, login, role)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "RevokeRoleDB", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
+
+// CreateInviteDB create invite code
+func (r *Repo) CreateInviteDB(ctx context.Context, invite *structs.Invite) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO users_schema.invites(code_hash, created_by, expires_at)
+				VALUES($1, $2, $3);`, invite.CodeHash, invite.CreatedBy, invite.ExpiresAt)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateInviteDB", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+// SetUserStatusDB set status of not deleted user
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) SetUserStatusDB(ctx context.Context, login string, status string) error {
+	lgr := logger.GetLogger()
+
+	res, err := r.db.Exec(ctx,
+		`UPDATE users_schema.users
+				SET status = $2, deactivated_at = CASE WHEN $2 = 'active' THEN NULL ELSE now() END
+				WHERE login = $1 AND status <> 'deleted';`, login, status)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SetUserStatusDB", "UPDATE")
+
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SetUserStatusDB", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// AnonymizeUserDB remove everything personal of user and rename him to anonLogin.
+// Rename goes to history, wallet and items through ON UPDATE CASCADE.
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) AnonymizeUserDB(ctx context.Context, login string, anonLogin string) error {
+	lgr := logger.GetLogger()
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM auth_schema.users_secrets WHERE login = $1;`,
+		`DELETE FROM auth_schema.api_keys WHERE login = $1;`,
+		`DELETE FROM auth_schema.totp WHERE login = $1;`,
+		`DELETE FROM auth_schema.login_attempts WHERE key = 'login:' || $1;`,
+		`DELETE FROM users_schema.user_roles WHERE login = $1;`,
+		`DELETE FROM users_schema.password_resets WHERE login = $1;`,
+		`DELETE FROM users_schema.profiles WHERE login = $1;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, login); err != nil {
+			lgr.Error(err.Error(), "Repo", "AnonymizeUserDB", "DELETE")
+
+			return err
+		}
+	}
+
+	// Пароль '!' не является хэшем, по нему нельзя войти
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users_schema.users
+				SET login = $2, password_hash = '!', status = 'deleted', deactivated_at = COALESCE(deactivated_at, now())
+				WHERE login = $1 AND status <> 'deleted';`, login, anonLogin)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "AnonymizeUserDB", "UPDATE")
+
+		return err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "AnonymizeUserDB", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "AnonymizeUserDB", "Commit")
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUserDB delete user with everything he owns.
+// Operations of user stay in history of other users with empty login.
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) DeleteUserDB(ctx context.Context, login string) error {
+	lgr := logger.GetLogger()
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM auth_schema.login_attempts WHERE key = 'login:' || $1;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteUserDB", "DELETE1")
+
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users_schema.users WHERE login = $1;`, login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteUserDB", "DELETE2")
+
+		return err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteUserDB", "RowsAffected")
+
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteUserDB", "Commit")
+
+		return err
+	}
+
+	return nil
+}
+
+// GetProfileDB get profile of not deleted user
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) GetProfileDB(ctx context.Context, login string) (structs.Profile, error) {
+	lgr := logger.GetLogger()
+
+	profile := structs.Profile{}
+
+	err := r.db.Get(ctx, &profile,
+		`SELECT u.login, u.status, COALESCE(p.display_name, '') AS display_name, COALESCE(p.department, '') AS department,
+				COALESCE(p.avatar_url, '') AS avatar_url, COALESCE(p.bio, '') AS bio, p.updated_at
+				FROM users_schema.users u LEFT JOIN users_schema.profiles p ON p.login = u.login
+				WHERE u.login = $1 AND u.status <> 'deleted';`, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return structs.Profile{}, repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "GetProfileDB", "SELECT")
+
+		return structs.Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// UpdateProfileDB create or update profile, nil fields are left as is
+// Returns repository.ErrObjectNotFound or err
+func (r *Repo) UpdateProfileDB(ctx context.Context, change structs.ProfileChange) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO users_schema.profiles(login, display_name, department, avatar_url, bio)
+				VALUES($1, COALESCE($2, ''), COALESCE($3, ''), COALESCE($4, ''), COALESCE($5, ''))
+				ON CONFLICT (login) DO UPDATE SET
+					display_name = COALESCE($2, profiles.display_name),
+					department = COALESCE($3, profiles.department),
+					avatar_url = COALESCE($4, profiles.avatar_url),
+					bio = COALESCE($5, profiles.bio),
+					updated_at = now();`,
+		change.Login, change.DisplayName, change.Department, change.AvatarURL, change.Bio)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, нет такого пользователя
+			return repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "UpdateProfileDB", "INSERT")
+
+		return err
+	}
+
+	return nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsersDB search active users by prefix of login or display name, then by trigram similarity
+func (r *Repo) SearchUsersDB(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, error) {
+	lgr := logger.GetLogger()
+
+	entries := []structs.DirectoryEntry{}
+
+	// Сначала совпадения по префиксу, затем похожие по триграммам (<% использует индексы gin_trgm_ops)
+	err := r.db.Select(ctx, &entries,
+		`SELECT u.login, COALESCE(p.display_name, '') AS display_name, COALESCE(p.department, '') AS department,
+				COALESCE(p.avatar_url, '') AS avatar_url
+				FROM users_schema.users u LEFT JOIN users_schema.profiles p ON p.login = u.login
+				WHERE u.status = 'active'
+					AND (u.login ILIKE $1 OR p.display_name ILIKE $1 OR $2 <% u.login OR $2 <% p.display_name)
+				ORDER BY (u.login ILIKE $1 OR COALESCE(p.display_name, '') ILIKE $1) DESC,
+					GREATEST(word_similarity($2, u.login), word_similarity($2, COALESCE(p.display_name, ''))) DESC,
+					u.login
+				LIMIT $3 OFFSET $4;`,
+		likeEscaper.Replace(filter.Query)+"%", filter.Query, filter.Limit, filter.Offset)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SearchUsersDB", "SELECT")
+
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// lockAccountsTx блокирует счета пользователей в порядке логинов, чтобы встречные операции
+// над одной парой счетов не ждали друг друга. Возвращает балансы по логину
+func lockAccountsTx(ctx context.Context, tx *sqlx.Tx, login1, login2 string) (map[string]int, error) {
+	rows := []struct {
+		Login   string `db:"login"`
+		Balance int    `db:"balance"`
+	}{}
+	err := tx.SelectContext(ctx, &rows,
+		`SELECT login, balance FROM users_schema.account WHERE login IN ($1, $2) ORDER BY login FOR UPDATE;`,
+		login1, login2)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]int, len(rows))
+	for _, row := range rows {
+		balances[row.Login] = row.Balance
+	}
+
+	return balances, nil
+}
+
+// insertLedgerTx append entries of one operation to ledger in transaction tx.
+// All entries get the same operation_id, it is returned.
+func insertLedgerTx(ctx context.Context, tx *sqlx.Tx, entries ...structs.LedgerEntry) (string, error) {
+	operationID, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO users_schema.ledger(operation_id, login, type, amount, counterparty, item, balance_after, message, tag, reverses)
+					VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`,
+			operationID.String(), e.Login, e.Type, e.Amount, e.Counterparty, e.Item, e.BalanceAfter, e.Message, e.Tag, e.Reverses)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return operationID.String(), nil
+}
+
+// GetHistoryDB get ledger entries of user, newest first.
+// Counterparty of deleted user is replaced with structs.DeletedUserName.
+func (r *Repo) GetHistoryDB(ctx context.Context, filter structs.HistoryFilter) ([]structs.LedgerEntry, error) {
+	lgr := logger.GetLogger()
+
+	entries := []structs.LedgerEntry{}
+
+	var afterTime *time.Time
+	afterID := ""
+	if filter.After != nil {
+		afterTime = &filter.After.CreatedAt
+		afterID = filter.After.ID
+	}
+
+	err := r.db.Select(ctx, &entries,
+		`SELECT l.id, l.operation_id, l.created_at, l.type, l.amount, l.item, l.balance_after, l.message, l.tag, l.reverses,
+				CASE WHEN u.status = 'deleted' OR (l.type = 'transfer' AND l.counterparty IS NULL) THEN $2
+					ELSE l.counterparty END AS counterparty
+				FROM users_schema.ledger l LEFT JOIN users_schema.users u ON u.login = l.counterparty
+				WHERE l.login = $1
+					AND (NULLIF($3, '') IS NULL OR ($3 = 'in' AND l.amount > 0) OR ($3 = 'out' AND l.amount < 0))
+					AND (NULLIF($4, '') IS NULL OR l.counterparty = $4)
+					AND (NULLIF($5, '') IS NULL OR l.type = $5)
+					AND ($6::timestamptz IS NULL OR l.created_at >= $6)
+					AND ($7::timestamptz IS NULL OR l.created_at < $7)
+					AND ($8::timestamptz IS NULL OR (l.created_at, l.id) < ($8, NULLIF($9, '')::uuid))
+				ORDER BY l.created_at DESC, l.id DESC
+				LIMIT $10;`,
+		filter.Login, structs.DeletedUserName, filter.Direction, filter.Counterparty, filter.Type,
+		filter.From, filter.To, afterTime, afterID, filter.Limit)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "GetHistoryDB", "SELECT")
+
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// CreateIdempotencyKeyDB save key of new request and lock it for lease. Keys of user older than retention are removed first.
+// Key of unfinished request with the same hash and expired lease is taken over, unless its operation is committed.
+// If key is already used returns saved record and false.
+func (r *Repo) CreateIdempotencyKeyDB(ctx context.Context, rec structs.IdempotencyRecord, retention time.Duration,
+	lease time.Duration) (structs.IdempotencyRecord, bool, error) {
+	lgr := logger.GetLogger()
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return structs.IdempotencyRecord{}, false, err
+	}
+	defer tx.Rollback()
+
+	// Чистим только ключи этого пользователя, чтобы таблица не росла без фоновой задачи
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM users_schema.idempotency_keys WHERE login = $1 AND created_at < now() - make_interval(secs => $2);`,
+		rec.Login, retention.Seconds())
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateIdempotencyKeyDB", "DELETE")
+
+		return structs.IdempotencyRecord{}, false, err
+	}
+
+	// Запрос, упавший вместе с сервером, не сохранил ответ и не освободил ключ, его повтор выполняется заново
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO users_schema.idempotency_keys(login, key, request_hash, locked_until)
+				VALUES($1, $2, $3, now() + make_interval(secs => $4))
+				ON CONFLICT (login, key) DO UPDATE SET locked_until = EXCLUDED.locked_until
+				WHERE idempotency_keys.status_code IS NULL AND idempotency_keys.operation_id IS NULL
+					AND idempotency_keys.locked_until < now() AND idempotency_keys.request_hash = EXCLUDED.request_hash;`,
+		rec.Login, rec.Key, rec.RequestHash, lease.Seconds())
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateIdempotencyKeyDB", "INSERT")
+
+		return structs.IdempotencyRecord{}, false, err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateIdempotencyKeyDB", "RowsAffected")
+
+		return structs.IdempotencyRecord{}, false, err
+	}
+
+	saved := rec
+	if cnt == 0 {
+		err = tx.GetContext(ctx, &saved,
+			`SELECT login, key, request_hash, status_code, response, operation_id FROM users_schema.idempotency_keys
+					WHERE login = $1 AND key = $2;`, rec.Login, rec.Key)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "CreateIdempotencyKeyDB", "SELECT")
+
+			return structs.IdempotencyRecord{}, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "CreateIdempotencyKeyDB", "Commit")
+
+		return structs.IdempotencyRecord{}, false, err
+	}
+
+	return saved, cnt > 0, nil
+}
+
+// SaveIdempotentResponseDB save result of request made with key
+func (r *Repo) SaveIdempotentResponseDB(ctx context.Context, login string, key string, statusCode int, response []byte) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE users_schema.idempotency_keys SET status_code = $3, response = $4
+				WHERE login = $1 AND key = $2;`, login, key, statusCode, response)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "SaveIdempotentResponseDB", "UPDATE")
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKeyDB forget key, so request can be retried with it. Key of committed operation is kept.
+func (r *Repo) DeleteIdempotencyKeyDB(ctx context.Context, login string, key string) error {
+	lgr := logger.GetLogger()
+
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM users_schema.idempotency_keys WHERE login = $1 AND key = $2 AND operation_id IS NULL;`, login, key)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "DeleteIdempotencyKeyDB", "DELETE")
+
+		return err
+	}
+
+	return nil
+}
+
+// bindIdempotencyKeyTx marks key as done by operation in its transaction. Empty key is ignored.
+// Key already bound to another operation (its lease was taken over) gives repository.ErrIdempotencyKeyUsed,
+// so operation is rolled back and isn't executed twice.
+func bindIdempotencyKeyTx(ctx context.Context, tx *sqlx.Tx, login string, key string, operationID string) error {
+	if key == "" {
+		return nil
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users_schema.idempotency_keys SET operation_id = $3
+				WHERE login = $1 AND key = $2 AND operation_id IS NULL;`, login, key, operationID)
+	if err != nil {
+		return err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return repository.ErrIdempotencyKeyUsed
+	}
+
+	return nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// getTransferStatsTx sum coins sent by user during last day and week, and to recipient during last day.
+// Reversed transfers are not counted.
+func getTransferStatsTx(ctx context.Context, tx *sqlx.Tx, login string, recipient string) (structs.TransferStats, error) {
+	stats := structs.TransferStats{}
+
+	err := tx.GetContext(ctx, &stats,
+		`SELECT COALESCE(SUM(-amount) FILTER (WHERE created_at > now() - interval '1 day'), 0) AS sent_day,
+				COALESCE(SUM(-amount), 0) AS sent_week,
+				COALESCE(SUM(-amount) FILTER (WHERE created_at > now() - interval '1 day' AND counterparty = $2), 0)
+					AS sent_to_recipient_day
+				FROM users_schema.ledger l
+				WHERE login = $1 AND type = 'transfer' AND amount < 0 AND created_at > now() - interval '7 days'
+					AND NOT EXISTS(SELECT 1 FROM users_schema.ledger r WHERE r.reverses = l.operation_id AND r.login = l.login);`,
+		login, recipient)
+	if err != nil {
+		return structs.TransferStats{}, err
+	}
+
+	return stats, nil
+}
+
+// ReverseOperationDB post compensating refund entries for every entry of operation, history is kept as is.
+// Purchase reversal also takes one bought item back.
+// Returns repository.ErrObjectNotFound, repository.ErrNotReversible, repository.ErrDuplicateKey (already reversed),
+// repository.ErrUndoExpired, repository.ErrCoinsSpent, repository.ErrCheckConstraint (not enough coins) or err
+func (r *Repo) ReverseOperationDB(ctx context.Context, reversal structs.Reversal) ([]structs.LedgerEntry, error) {
+	lgr := logger.GetLogger()
+
+	tx, err := r.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entries := []structs.LedgerEntry{}
+	err = tx.SelectContext(ctx, &entries,
+		`SELECT id, operation_id, created_at, login, type, amount, counterparty, item, balance_after, message, tag, reverses
+				FROM users_schema.ledger WHERE operation_id = $1 ORDER BY amount FOR UPDATE;`, reversal.OperationID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // не uuid
+			return nil, repository.ErrObjectNotFound
+		}
+		lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "SELECT1")
+
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, repository.ErrObjectNotFound
+	}
+
+	opType := entries[0].Type
+	if opType != structs.LedgerTypeTransfer && opType != structs.LedgerTypePurchase {
+		return nil, repository.ErrNotReversible
+	}
+	for _, e := range entries {
+		// Счёт удалённого пользователя уже не существует
+		if e.Login == nil {
+			return nil, repository.ErrNotReversible
+		}
+	}
+
+	// Блокируем счета участников в том же порядке, что и SendCoinDB, до конца отмены их балансы не изменятся
+	balances, err := lockAccountsTx(ctx, tx, *entries[0].Login, *entries[len(entries)-1].Login)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "LOCK")
+
+		return nil, err
+	}
+
+	if reversal.Sender != "" {
+		// Отменить можно только свой перевод. Записи отсортированы по amount, первой идёт списание у отправителя
+		sent, received := entries[0], entries[len(entries)-1]
+		if opType != structs.LedgerTypeTransfer || len(entries) != 2 || *sent.Login != reversal.Sender {
+			return nil, repository.ErrObjectNotFound
+		}
+		// Время сравниваем по часам БД, которыми записан created_at
+		expired := false
+		err = tx.GetContext(ctx, &expired,
+			`SELECT now() - created_at > make_interval(secs => $2) FROM users_schema.ledger WHERE id = $1;`,
+			sent.ID, reversal.Window.Seconds())
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "SELECT2")
+
+			return nil, err
+		}
+		if expired {
+			return nil, repository.ErrUndoExpired
+		}
+
+		// Баланс, отличный от balance_after записи перевода, значит, что после перевода по счёту были операции
+		recipientBalance, ok := balances[*received.Login]
+		if !ok {
+			return nil, repository.ErrNotReversible
+		}
+		if recipientBalance != received.BalanceAfter {
+			return nil, repository.ErrCoinsSpent
+		}
+	}
+
+	refunds := make([]structs.LedgerEntry, 0, len(entries))
+	for _, e := range entries {
+		balance := 0
+		err = tx.QueryRowContext(ctx,
+			`UPDATE users_schema.account SET balance = balance - $1
+					WHERE login = $2 returning balance;`, e.Amount, *e.Login).Scan(&balance)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+				return nil, repository.ErrNotReversible
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23514" { // check constraint, получатель уже потратил монеты
+				return nil, repository.ErrCheckConstraint
+			}
+			lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "UPDATE")
+
+			return nil, err
+		}
+
+		refunds = append(refunds, structs.LedgerEntry{
+			Login: e.Login, Type: structs.LedgerTypeRefund, Amount: -e.Amount, Counterparty: e.Counterparty,
+			Item: e.Item, BalanceAfter: balance, Message: nullIfEmpty(reversal.Reason), Reverses: &e.OperationID,
+		})
+	}
+
+	switch opType {
+	case structs.LedgerTypeTransfer:
+		// Отрицательная операция сохраняет суммы в агрегированной истории /api/info
+		sent := entries[0]
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO users_schema.user_operations(sender, recipient, amount) VALUES($1, $2, $3);`,
+			*sent.Login, *sent.Counterparty, sent.Amount)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "INSERT")
+
+			return nil, err
+		}
+	case structs.LedgerTypePurchase:
+		e := entries[0]
+		res, err := tx.ExecContext(ctx,
+			`DELETE FROM users_schema.user_items WHERE ctid =
+					(SELECT ctid FROM users_schema.user_items WHERE login = $1 AND item = $2 LIMIT 1);`,
+			*e.Login, *e.Item)
+		if err != nil {
+			lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "DELETE")
+
+			return nil, err
+		}
+		// Предмета в инвентаре уже нет, вернуть монеты без него нельзя
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			return nil, repository.ErrNotReversible
+		}
+	}
+
+	operationID, err := insertLedgerTx(ctx, tx, refunds...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // операция уже отменена
+			return nil, repository.ErrDuplicateKey
+		}
+		lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "insertLedgerTx")
+
+		return nil, err
+	}
+
+	posted := []structs.LedgerEntry{}
+	err = tx.SelectContext(ctx, &posted,
+		`SELECT id, operation_id, created_at, login, type, amount, counterparty, item, balance_after, message, tag, reverses
+				FROM users_schema.ledger WHERE operation_id = $1 ORDER BY amount;`, operationID)
+	if err != nil {
+		lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "SELECT4")
+
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		lgr.Error(err.Error(), "Repo", "ReverseOperationDB", "Commit")
+
+		return nil, err
+	}
+
+	return posted, nil
+}
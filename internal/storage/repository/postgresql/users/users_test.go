@@ -3,20 +3,27 @@ package users
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models/structs"
 	"github.com/Kapeland/task-Avito/internal/storage"
 	"github.com/Kapeland/task-Avito/internal/storage/db"
+	"github.com/Kapeland/task-Avito/internal/storage/repository"
+	"github.com/Kapeland/task-Avito/internal/storage/repository/postgresql/catalog"
 	"github.com/Kapeland/task-Avito/internal/utils/config"
 	"github.com/Kapeland/task-Avito/internal/utils/logger"
+	"github.com/Kapeland/task-Avito/internal/utils/passhash"
 )
 
 func TestNew(t *testing.T) {
 	type args struct {
-		db db.DBops
+		db      db.DBops
+		catalog *catalog.Repo
+		hasher  passhash.Hasher
 	}
 	ctx := context.Background()
 	if err := config.ReadLocalConfigYAML(); err != nil {
@@ -28,6 +35,11 @@ func TestNew(t *testing.T) {
 	if err != nil {
 		t.Error("NewPostgresStorage: " + err.Error())
 	}
+	catalogRepo := catalog.New(dbStor.DB)
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		t.Error("passhash.New: " + err.Error())
+	}
 	tests := []struct {
 		name string
 		args args
@@ -35,13 +47,13 @@ func TestNew(t *testing.T) {
 	}{
 		{
 			name: "Init DB",
-			args: args{db: dbStor.DB},
-			want: &Repo{db: dbStor.DB},
+			args: args{db: dbStor.DB, catalog: catalogRepo, hasher: hasher},
+			want: &Repo{db: dbStor.DB, catalog: catalogRepo, hasher: hasher},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := New(tt.args.db); !reflect.DeepEqual(got, tt.want) {
+			if got := New(tt.args.db, tt.args.catalog, tt.args.hasher); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("New() = %v, want %v", got, tt.want)
 			}
 		})
@@ -50,7 +62,8 @@ func TestNew(t *testing.T) {
 
 func TestRepo_BuyItem(t *testing.T) {
 	type fields struct {
-		db db.DBops
+		db      db.DBops
+		catalog *catalog.Repo
 	}
 	type args struct {
 		ctx   context.Context
@@ -75,7 +88,7 @@ func TestRepo_BuyItem(t *testing.T) {
 	}{
 		{
 			name:   "Buy existing item, money enough",
-			fields: fields{db: dbStor.DB},
+			fields: fields{db: dbStor.DB, catalog: catalog.New(dbStor.DB)},
 			args: args{
 				ctx:   ctx,
 				item:  "pen",
@@ -85,7 +98,7 @@ func TestRepo_BuyItem(t *testing.T) {
 		},
 		{
 			name:   "Buy existing item, money not enough",
-			fields: fields{db: dbStor.DB},
+			fields: fields{db: dbStor.DB, catalog: catalog.New(dbStor.DB)},
 			args: args{
 				ctx:   ctx,
 				item:  "pink-hoody",
@@ -95,7 +108,7 @@ func TestRepo_BuyItem(t *testing.T) {
 		},
 		{
 			name:   "Buy not existing item, money enough",
-			fields: fields{db: dbStor.DB},
+			fields: fields{db: dbStor.DB, catalog: catalog.New(dbStor.DB)},
 			args: args{
 				ctx:   ctx,
 				item:  "red-hoody",
@@ -105,7 +118,7 @@ func TestRepo_BuyItem(t *testing.T) {
 		},
 		{
 			name:   "User not exists",
-			fields: fields{db: dbStor.DB},
+			fields: fields{db: dbStor.DB, catalog: catalog.New(dbStor.DB)},
 			args: args{
 				ctx:   ctx,
 				item:  "pen",
@@ -117,9 +130,10 @@ func TestRepo_BuyItem(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &Repo{
-				db: tt.fields.db,
+				db:      tt.fields.db,
+				catalog: tt.fields.catalog,
 			}
-			if err := r.BuyItemDB(tt.args.ctx, tt.args.item, tt.args.login); (err != nil) != tt.wantErr {
+			if err := r.BuyItemDB(tt.args.ctx, tt.args.item, tt.args.login, ""); (err != nil) != tt.wantErr {
 				t.Errorf("BuyItemDB() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -146,6 +160,11 @@ func TestRepo_CreateUser(t *testing.T) {
 		t.Error("NewPostgresStorage: " + err.Error())
 	}
 
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		t.Error("passhash.New: " + err.Error())
+	}
+
 	tmpNumb, err := genInt(5)
 	if err != nil {
 		t.Error("genInt: " + err.Error())
@@ -197,10 +216,11 @@ func TestRepo_CreateUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &Repo{
-				db: tt.fields.db,
+				db:     tt.fields.db,
+				hasher: hasher,
 			}
 
-			if err := r.CreateUserDB(tt.args.ctx, tt.args.info); (err != nil) != tt.wantErr {
+			if err := r.CreateUserDB(tt.args.ctx, tt.args.info, ""); (err != nil) != tt.wantErr {
 				t.Errorf("CreateUserDB() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -372,13 +392,135 @@ func TestRepo_SendCoinTo(t *testing.T) {
 			r := &Repo{
 				db: tt.fields.db,
 			}
-			if err := r.SendCoinDB(tt.args.ctx, tt.args.operation); (err != nil) != tt.wantErr {
+			if err := r.SendCoinDB(tt.args.ctx, tt.args.operation, nil); (err != nil) != tt.wantErr {
 				t.Errorf("SendCoinDB() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestRepo_SendCoin_Ledger(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB}
+
+	err = r.SendCoinDB(ctx, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 1}, nil)
+	if err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+
+	entries := []structs.LedgerEntry{}
+	err = dbStor.DB.Select(ctx, &entries,
+		`SELECT * FROM users_schema.ledger WHERE operation_id =
+				(SELECT operation_id FROM users_schema.ledger WHERE login = 'user1user1' ORDER BY created_at DESC LIMIT 1)
+				ORDER BY amount;`)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d ledger entries, want 2", len(entries))
+	}
+
+	balances := map[string]int{}
+	for _, login := range []string{"user1user1", "user1user2"} {
+		balance := 0
+		if err := dbStor.DB.Get(ctx, &balance, `SELECT balance FROM users_schema.account WHERE login = $1;`, login); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		balances[login] = balance
+	}
+
+	if *entries[0].Login != "user1user1" || entries[0].Amount != -1 || *entries[0].Counterparty != "user1user2" ||
+		entries[0].BalanceAfter != balances["user1user1"] {
+		t.Errorf("sender entry = %+v", entries[0])
+	}
+	if *entries[1].Login != "user1user2" || entries[1].Amount != 1 || *entries[1].Counterparty != "user1user1" ||
+		entries[1].BalanceAfter != balances["user1user2"] {
+		t.Errorf("recipient entry = %+v", entries[1])
+	}
+
+	// Леджер только дописывается
+	if _, err := dbStor.DB.Exec(ctx, `DELETE FROM users_schema.ledger WHERE id = $1;`, entries[0].ID); err == nil {
+		t.Error("ledger entry was deleted")
+	}
+	if _, err := dbStor.DB.Exec(ctx, `UPDATE users_schema.ledger SET amount = 0 WHERE id = $1;`, entries[0].ID); err == nil {
+		t.Error("ledger entry was updated")
+	}
+}
+
+func TestRepo_SendCoin_Limits(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB}
+
+	operation := structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 2}
+	send := func() structs.TransferStats {
+		var got structs.TransferStats
+		err := r.SendCoinDB(ctx, operation, func(stats structs.TransferStats) error {
+			got = stats
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SendCoinDB() error = %v", err)
+		}
+		return got
+	}
+
+	before := send()
+	if got := send(); got.SentDay != before.SentDay+2 || got.SentToRecipientDay != before.SentToRecipientDay+2 {
+		t.Errorf("stats = %+v, before = %+v", got, before)
+	}
+
+	// Отменённый перевод не учитывается в лимитах
+	opID := ""
+	err = dbStor.DB.Get(ctx, &opID,
+		`SELECT operation_id FROM users_schema.ledger WHERE login = 'user1user1' AND type = 'transfer'
+				ORDER BY created_at DESC LIMIT 1;`)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, err := r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "admin"}); err != nil {
+		t.Fatalf("ReverseOperationDB() error = %v", err)
+	}
+	if got := send(); got.SentDay != before.SentDay+2 || got.SentWeek != before.SentWeek+2 {
+		t.Errorf("stats after reversal = %+v, before = %+v", got, before)
+	}
+
+	// Ошибка проверки откатывает перевод
+	balance := func() int {
+		b := 0
+		if err := dbStor.DB.Get(ctx, &b, `SELECT balance FROM users_schema.account WHERE login = 'user1user1';`); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return b
+	}
+	errLimit := errors.New("limit")
+	was := balance()
+	err = r.SendCoinDB(ctx, operation, func(structs.TransferStats) error { return errLimit })
+	if !errors.Is(err, errLimit) {
+		t.Errorf("SendCoinDB() error = %v, want %v", err, errLimit)
+	}
+	if balance() != was {
+		t.Error("transfer rejected by limits is committed")
+	}
+}
+
 func TestRepo_VerifyPassword(t *testing.T) {
 	type fields struct {
 		db db.DBops
@@ -399,6 +541,11 @@ func TestRepo_VerifyPassword(t *testing.T) {
 		t.Error("NewPostgresStorage: " + err.Error())
 	}
 
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		t.Error("passhash.New: " + err.Error())
+	}
+
 	tmpNumb, err := genInt(5)
 	if err != nil {
 		t.Error("genInt: " + err.Error())
@@ -454,7 +601,8 @@ func TestRepo_VerifyPassword(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &Repo{
-				db: tt.fields.db,
+				db:     tt.fields.db,
+				hasher: hasher,
 			}
 			got, err := r.VerifyPasswordDB(tt.args.ctx, tt.args.info)
 			if (err != nil) != tt.wantErr {
@@ -468,6 +616,222 @@ func TestRepo_VerifyPassword(t *testing.T) {
 	}
 }
 
+// countingHasher remembers hashes passed to Verify
+type countingHasher struct {
+	passhash.Hasher
+	verified []string
+}
+
+func (h *countingHasher) Verify(hash string, password string) (bool, error) {
+	h.verified = append(h.verified, hash)
+	return h.Hasher.Verify(hash, password)
+}
+
+func TestRepo_VerifyPassword_UnknownUser(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		t.Fatal("passhash.New: " + err.Error())
+	}
+	counting := &countingHasher{Hasher: hasher}
+	r := &Repo{db: dbStor.DB, hasher: counting}
+
+	tmpNumb, err := genInt(5)
+	if err != nil {
+		t.Fatal("genInt: " + err.Error())
+	}
+
+	// Для неизвестного логина проверяется фиктивный хэш настроенного алгоритма, как для существующего
+	for i := 0; i < 2; i++ {
+		ok, err := r.VerifyPasswordDB(ctx, structs.AuthUserInfo{Login: "nouser" + tmpNumb, Pswd: "Lhjxb[eq1"})
+		if err != nil || ok {
+			t.Fatalf("VerifyPasswordDB() = %v, %v", ok, err)
+		}
+	}
+	if len(counting.verified) != 2 {
+		t.Fatalf("Verify called %d times, want 2", len(counting.verified))
+	}
+	if hash := counting.verified[0]; hash == "" || hasher.NeedsRehash(hash) || hash != counting.verified[1] {
+		t.Errorf("dummy hash = %q", hash)
+	}
+}
+
+func TestRepo_IdempotencyKey_CrashAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB}
+
+	tmpNumb, err := genInt(5)
+	if err != nil {
+		t.Fatal("genInt: " + err.Error())
+	}
+	rec := structs.IdempotencyRecord{Login: "user1user1", Key: "crash" + tmpNumb, RequestHash: "hash"}
+	balance := func() int {
+		b := 0
+		if err := dbStor.DB.Get(ctx, &b, `SELECT balance FROM users_schema.account WHERE login = 'user1user1';`); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return b
+	}
+
+	if _, created, err := r.CreateIdempotencyKeyDB(ctx, rec, time.Hour, time.Millisecond); err != nil || !created {
+		t.Fatalf("CreateIdempotencyKeyDB() = %v, %v", created, err)
+	}
+	operation := structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 1, IdempotencyKey: rec.Key}
+	if err := r.SendCoinDB(ctx, operation, nil); err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+	// Сервер упал до сохранения ответа: аренда истекла, а ответ не записан
+	before := balance()
+	time.Sleep(10 * time.Millisecond)
+
+	saved, created, err := r.CreateIdempotencyKeyDB(ctx, rec, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("CreateIdempotencyKeyDB() error = %v", err)
+	}
+	if created || saved.OperationID == nil || saved.StatusCode != nil {
+		t.Errorf("key of committed operation: created = %v, operation = %v, status = %v", created, saved.OperationID, saved.StatusCode)
+	}
+
+	// Ключ выполненной операции не освобождается, а повтор операции с ним откатывается
+	if err := r.DeleteIdempotencyKeyDB(ctx, rec.Login, rec.Key); err != nil {
+		t.Fatalf("DeleteIdempotencyKeyDB() error = %v", err)
+	}
+	if err := r.SendCoinDB(ctx, operation, nil); !errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+		t.Errorf("second SendCoinDB() error = %v, want %v", err, repository.ErrIdempotencyKeyUsed)
+	}
+	if balance() != before {
+		t.Error("transfer is executed twice")
+	}
+}
+
+func TestRepo_AnonymizeUser(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	hasher, err := passhash.New(cfg.Auth.PasswordHashing)
+	if err != nil {
+		t.Fatal("passhash.New: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB, hasher: hasher}
+
+	tmpNumb, err := genInt(5)
+	if err != nil {
+		t.Fatal("genInt: " + err.Error())
+	}
+	login, anonLogin := "anonuser"+tmpNumb, "deleted-anonuser"+tmpNumb
+
+	if err := r.CreateUserDB(ctx, structs.RegisterUserInfo{Login: login, Pswd: "Lhjxb[eq" + tmpNumb}, ""); err != nil {
+		t.Fatalf("CreateUserDB() error = %v", err)
+	}
+	name, bio := "Real Name", "about me"
+	if err := r.UpdateProfileDB(ctx, structs.ProfileChange{Login: login, DisplayName: &name, Bio: &bio}); err != nil {
+		t.Fatalf("UpdateProfileDB() error = %v", err)
+	}
+
+	if err := r.AnonymizeUserDB(ctx, login, anonLogin); err != nil {
+		t.Fatalf("AnonymizeUserDB() error = %v", err)
+	}
+
+	// Профиль с персональными данными не должен переехать на анонимный логин
+	cnt := 0
+	err = dbStor.DB.Get(ctx, &cnt, `SELECT count(*) FROM users_schema.profiles WHERE login IN ($1, $2);`, login, anonLogin)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cnt != 0 {
+		t.Errorf("profiles left after anonymization = %d, want 0", cnt)
+	}
+}
+
+func TestRepo_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB}
+
+	tmpNumb, err := genInt(5)
+	if err != nil {
+		t.Fatal("genInt: " + err.Error())
+	}
+	rec := structs.IdempotencyRecord{Login: "user1user1", Key: "key" + tmpNumb, RequestHash: "hash"}
+	create := func(rec structs.IdempotencyRecord, lease time.Duration) (structs.IdempotencyRecord, bool) {
+		saved, created, err := r.CreateIdempotencyKeyDB(ctx, rec, time.Hour, lease)
+		if err != nil {
+			t.Fatalf("CreateIdempotencyKeyDB() error = %v", err)
+		}
+		return saved, created
+	}
+
+	if _, created := create(rec, time.Hour); !created {
+		t.Fatal("new key is not created")
+	}
+	// Пока держится аренда, повтор видит незавершённый запрос
+	if saved, created := create(rec, time.Hour); created || saved.StatusCode != nil {
+		t.Errorf("in progress key: created = %v, status = %v", created, saved.StatusCode)
+	}
+
+	// Ответ 5xx освобождает ключ
+	if err := r.DeleteIdempotencyKeyDB(ctx, rec.Login, rec.Key); err != nil {
+		t.Fatalf("DeleteIdempotencyKeyDB() error = %v", err)
+	}
+	if _, created := create(rec, time.Millisecond); !created {
+		t.Error("released key is not created again")
+	}
+
+	// Аренда истекла: другой запрос с тем же ключом не перехватывает его, тот же запрос перехватывает
+	time.Sleep(10 * time.Millisecond)
+	other := rec
+	other.RequestHash = "other"
+	if saved, created := create(other, time.Hour); created || saved.RequestHash != rec.RequestHash {
+		t.Errorf("key with other hash: created = %v, hash = %v", created, saved.RequestHash)
+	}
+	if _, created := create(rec, time.Millisecond); !created {
+		t.Error("key with expired lease is not taken over")
+	}
+
+	// Сохранённый ответ не перехватывается даже после истечения аренды
+	if err := r.SaveIdempotentResponseDB(ctx, rec.Login, rec.Key, 200, []byte(`{}`)); err != nil {
+		t.Fatalf("SaveIdempotentResponseDB() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	saved, created := create(rec, time.Hour)
+	if created || saved.StatusCode == nil || *saved.StatusCode != 200 {
+		t.Errorf("finished key: created = %v, status = %v", created, saved.StatusCode)
+	}
+}
+
 func genInt(length int) (string, error) {
 	result := ""
 	for {
@@ -484,3 +848,153 @@ func genInt(length int) (string, error) {
 		}
 	}
 }
+
+func TestRepo_ReverseOperation(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB}
+
+	balance := func(login string) int {
+		b := 0
+		if err := dbStor.DB.Get(ctx, &b, `SELECT balance FROM users_schema.account WHERE login = $1;`, login); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return b
+	}
+	lastTransfer := func() string {
+		id := ""
+		err := dbStor.DB.Get(ctx, &id,
+			`SELECT operation_id FROM users_schema.ledger WHERE login = 'user1user1' AND type = 'transfer'
+					ORDER BY created_at DESC LIMIT 1;`)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return id
+	}
+
+	before1, before2 := balance("user1user1"), balance("user1user2")
+	if err := r.SendCoinDB(ctx, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 3}, nil); err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+	opID := lastTransfer()
+
+	// Отменить чужой перевод нельзя
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "user1user2", Sender: "user1user2", Window: time.Hour})
+	if !errors.Is(err, repository.ErrObjectNotFound) {
+		t.Errorf("undo by recipient error = %v, want %v", err, repository.ErrObjectNotFound)
+	}
+
+	entries, err := r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "admin", Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("ReverseOperationDB() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Type != structs.LedgerTypeRefund || *entries[0].Reverses != opID {
+		t.Errorf("ReverseOperationDB() entries = %+v", entries)
+	}
+	if balance("user1user1") != before1 || balance("user1user2") != before2 {
+		t.Error("balances are not restored")
+	}
+
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "admin"})
+	if !errors.Is(err, repository.ErrDuplicateKey) {
+		t.Errorf("second reversal error = %v, want %v", err, repository.ErrDuplicateKey)
+	}
+
+	// Отмена отправителем в пределах окна
+	if err := r.SendCoinDB(ctx, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 3}, nil); err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+	opID = lastTransfer()
+
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "user1user1", Sender: "user1user1", Window: time.Nanosecond})
+	if !errors.Is(err, repository.ErrUndoExpired) {
+		t.Errorf("expired undo error = %v, want %v", err, repository.ErrUndoExpired)
+	}
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "user1user1", Sender: "user1user1", Window: time.Hour})
+	if err != nil {
+		t.Errorf("undo error = %v", err)
+	}
+
+	// После перевода получатель уже распорядился монетами
+	if err := r.SendCoinDB(ctx, structs.SendCoinInfo{From: "user1user1", To: "user1user2", Amount: 3}, nil); err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+	opID = lastTransfer()
+	if err := r.SendCoinDB(ctx, structs.SendCoinInfo{From: "user1user2", To: "user1user1", Amount: 1}, nil); err != nil {
+		t.Fatalf("SendCoinDB() error = %v", err)
+	}
+
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "user1user1", Sender: "user1user1", Window: time.Hour})
+	if !errors.Is(err, repository.ErrCoinsSpent) {
+		t.Errorf("undo after spending error = %v, want %v", err, repository.ErrCoinsSpent)
+	}
+
+	// Полностью отменённые переводы не оставляют в истории строк с нулевой суммой
+	info, err := r.GetInfoDB(ctx, "user1user1")
+	if err != nil {
+		t.Fatalf("GetInfoDB() error = %v", err)
+	}
+	for _, sent := range info.CoinHistory.Sent {
+		if sent.Amount == 0 {
+			t.Errorf("GetInfoDB() sent = %+v, zero amount", sent)
+		}
+	}
+	for _, received := range info.CoinHistory.Received {
+		if received.Amount == 0 {
+			t.Errorf("GetInfoDB() received = %+v, zero amount", received)
+		}
+	}
+}
+
+func TestRepo_ReverseOperation_Purchase(t *testing.T) {
+	ctx := context.Background()
+	if err := config.ReadLocalConfigYAML(); err != nil {
+		t.Error(err)
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		t.Fatal("NewPostgresStorage: " + err.Error())
+	}
+	r := &Repo{db: dbStor.DB, catalog: catalog.New(dbStor.DB)}
+
+	lastPurchase := func() string {
+		id := ""
+		err := dbStor.DB.Get(ctx, &id,
+			`SELECT operation_id FROM users_schema.ledger WHERE login = 'user1user1' AND type = 'purchase'
+					ORDER BY created_at DESC LIMIT 1;`)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return id
+	}
+
+	if err := r.BuyItemDB(ctx, "pen", "user1user1", ""); err != nil {
+		t.Fatalf("BuyItemDB() error = %v", err)
+	}
+	if _, err := r.ReverseOperationDB(ctx, structs.Reversal{OperationID: lastPurchase(), By: "admin"}); err != nil {
+		t.Errorf("ReverseOperationDB() error = %v", err)
+	}
+
+	// Предмета в инвентаре уже нет, отмена без него не проходит
+	if err := r.BuyItemDB(ctx, "pen", "user1user1", ""); err != nil {
+		t.Fatalf("BuyItemDB() error = %v", err)
+	}
+	opID := lastPurchase()
+	if _, err := dbStor.DB.Exec(ctx, `DELETE FROM users_schema.user_items WHERE login = 'user1user1' AND item = 'pen';`); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	_, err = r.ReverseOperationDB(ctx, structs.Reversal{OperationID: opID, By: "admin"})
+	if !errors.Is(err, repository.ErrNotReversible) {
+		t.Errorf("reversal without item error = %v, want %v", err, repository.ErrNotReversible)
+	}
+}
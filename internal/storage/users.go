@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Kapeland/task-Avito/internal/models"
 	"github.com/Kapeland/task-Avito/internal/models/structs"
@@ -10,11 +11,29 @@ import (
 )
 
 type UsersRepo interface {
-	CreateUserDB(ctx context.Context, info structs.RegisterUserInfo) error
+	CreateUserDB(ctx context.Context, info structs.RegisterUserInfo, inviteHash string) error
+	CreateInviteDB(ctx context.Context, invite *structs.Invite) error
 	VerifyPasswordDB(ctx context.Context, info structs.AuthUserInfo) (bool, error)
-	SendCoinDB(ctx context.Context, operation structs.SendCoinInfo) error
-	BuyItemDB(ctx context.Context, item string, login string) error
+	UpdatePasswordDB(ctx context.Context, info structs.AuthUserInfo) error
+	CreatePasswordResetDB(ctx context.Context, reset *structs.PasswordReset) error
+	RedeemPasswordResetDB(ctx context.Context, tokenHash string, newPswd string) (string, error)
+	SendCoinDB(ctx context.Context, operation structs.SendCoinInfo, checkLimits func(structs.TransferStats) error) error
+	BuyItemDB(ctx context.Context, item string, login string, idempotencyKey string) error
 	GetInfoDB(ctx context.Context, login string) (*structs.AccInfo, error)
+	GetRolesDB(ctx context.Context, login string) ([]string, error)
+	GrantRoleDB(ctx context.Context, login string, role string) error
+	RevokeRoleDB(ctx context.Context, login string, role string) error
+	SetUserStatusDB(ctx context.Context, login string, status string) error
+	AnonymizeUserDB(ctx context.Context, login string, anonLogin string) error
+	DeleteUserDB(ctx context.Context, login string) error
+	GetProfileDB(ctx context.Context, login string) (structs.Profile, error)
+	UpdateProfileDB(ctx context.Context, change structs.ProfileChange) error
+	SearchUsersDB(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, error)
+	GetHistoryDB(ctx context.Context, filter structs.HistoryFilter) ([]structs.LedgerEntry, error)
+	CreateIdempotencyKeyDB(ctx context.Context, rec structs.IdempotencyRecord, retention time.Duration, lease time.Duration) (structs.IdempotencyRecord, bool, error)
+	SaveIdempotentResponseDB(ctx context.Context, login string, key string, statusCode int, response []byte) error
+	DeleteIdempotencyKeyDB(ctx context.Context, login string, key string) error
+	ReverseOperationDB(ctx context.Context, reversal structs.Reversal) ([]structs.LedgerEntry, error)
 }
 
 type UsersStorage struct {
@@ -26,18 +45,27 @@ func NewUsersStorage(usersRepo UsersRepo) UsersStorage {
 }
 
 // CreateUserST user
-func (s *UsersStorage) CreateUserST(ctx context.Context, info structs.RegisterUserInfo) error {
-	err := s.usersRepo.CreateUserDB(ctx, info)
+// Returns models.ErrUserConflict, models.ErrInvalidInvite or err
+func (s *UsersStorage) CreateUserST(ctx context.Context, info structs.RegisterUserInfo, inviteHash string) error {
+	err := s.usersRepo.CreateUserDB(ctx, info, inviteHash)
 	if err != nil {
 		if errors.Is(err, repository.ErrDuplicateKey) {
 			return models.ErrUserConflict
 		}
+		if errors.Is(err, repository.ErrInvalidInvite) {
+			return models.ErrInvalidInvite
+		}
 		return err
 	}
 
 	return nil
 }
 
+// CreateInviteST invite
+func (s *UsersStorage) CreateInviteST(ctx context.Context, invite structs.Invite) error {
+	return s.usersRepo.CreateInviteDB(ctx, &invite)
+}
+
 // CheckPasswordST user
 func (s *UsersStorage) CheckPasswordST(ctx context.Context, info structs.AuthUserInfo) (bool, error) {
 	ok, err := s.usersRepo.VerifyPasswordDB(ctx, info)
@@ -47,28 +75,68 @@ func (s *UsersStorage) CheckPasswordST(ctx context.Context, info structs.AuthUse
 	return ok, nil
 }
 
+// UpdatePasswordST user
+func (s *UsersStorage) UpdatePasswordST(ctx context.Context, info structs.AuthUserInfo) error {
+	return s.usersRepo.UpdatePasswordDB(ctx, info)
+}
+
+// CreatePasswordResetST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) CreatePasswordResetST(ctx context.Context, reset structs.PasswordReset) error {
+	err := s.usersRepo.CreatePasswordResetDB(ctx, &reset)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RedeemPasswordResetST user
+// Returns models.ErrInvalidToken or err
+func (s *UsersStorage) RedeemPasswordResetST(ctx context.Context, tokenHash string, newPswd string) (string, error) {
+	login, err := s.usersRepo.RedeemPasswordResetDB(ctx, tokenHash, newPswd)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return "", models.ErrInvalidToken
+		}
+		return "", err
+	}
+	return login, nil
+}
+
 // SendCoinST user
-func (s *UsersStorage) SendCoinST(ctx context.Context, operation structs.SendCoinInfo) error {
-	err := s.usersRepo.SendCoinDB(ctx, operation)
+func (s *UsersStorage) SendCoinST(ctx context.Context, operation structs.SendCoinInfo, checkLimits func(structs.TransferStats) error) error {
+	err := s.usersRepo.SendCoinDB(ctx, operation, checkLimits)
 	if err != nil {
 		if errors.Is(err, repository.ErrObjectNotFound) {
 			return models.ErrUserNotFound
 		}
+		if errors.Is(err, repository.ErrUserInactive) {
+			return models.ErrUserDeactivated
+		}
 		if errors.Is(err, repository.ErrCheckConstraint) {
 			return models.ErrInsufficientBalance
 		}
+		if errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+			return models.ErrIdempotencyInProgress
+		}
 		return err
 	}
 	return nil
 }
 
 // BuyItemST user
-func (s *UsersStorage) BuyItemST(ctx context.Context, item string, login string) error {
-	err := s.usersRepo.BuyItemDB(ctx, item, login)
+func (s *UsersStorage) BuyItemST(ctx context.Context, item string, login string, idempotencyKey string) error {
+	err := s.usersRepo.BuyItemDB(ctx, item, login, idempotencyKey)
 	if err != nil {
 		if errors.Is(err, repository.ErrNoSuchItem) {
 			return models.ErrNoSuchItem
 		}
+		if errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+			return models.ErrIdempotencyInProgress
+		}
 		return err
 	}
 	return nil
@@ -85,3 +153,145 @@ func (s *UsersStorage) GetInfoST(ctx context.Context, login string) (structs.Acc
 	}
 	return *info, err
 }
+
+// GetRolesST user
+func (s *UsersStorage) GetRolesST(ctx context.Context, login string) ([]string, error) {
+	return s.usersRepo.GetRolesDB(ctx, login)
+}
+
+// GrantRoleST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) GrantRoleST(ctx context.Context, login string, role string) error {
+	err := s.usersRepo.GrantRoleDB(ctx, login, role)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RevokeRoleST user
+func (s *UsersStorage) RevokeRoleST(ctx context.Context, login string, role string) error {
+	return s.usersRepo.RevokeRoleDB(ctx, login, role)
+}
+
+// SetUserStatusST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) SetUserStatusST(ctx context.Context, login string, status string) error {
+	err := s.usersRepo.SetUserStatusDB(ctx, login, status)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AnonymizeUserST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) AnonymizeUserST(ctx context.Context, login string, anonLogin string) error {
+	err := s.usersRepo.AnonymizeUserDB(ctx, login, anonLogin)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteUserST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) DeleteUserST(ctx context.Context, login string) error {
+	err := s.usersRepo.DeleteUserDB(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetProfileST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) GetProfileST(ctx context.Context, login string) (structs.Profile, error) {
+	profile, err := s.usersRepo.GetProfileDB(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.Profile{}, models.ErrUserNotFound
+		}
+		return structs.Profile{}, err
+	}
+	return profile, nil
+}
+
+// UpdateProfileST user
+// Returns models.ErrUserNotFound or err
+func (s *UsersStorage) UpdateProfileST(ctx context.Context, change structs.ProfileChange) error {
+	err := s.usersRepo.UpdateProfileDB(ctx, change)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SearchUsersST users
+func (s *UsersStorage) SearchUsersST(ctx context.Context, filter structs.UsersFilter) ([]structs.DirectoryEntry, error) {
+	return s.usersRepo.SearchUsersDB(ctx, filter)
+}
+
+// GetHistoryST user
+func (s *UsersStorage) GetHistoryST(ctx context.Context, filter structs.HistoryFilter) ([]structs.LedgerEntry, error) {
+	return s.usersRepo.GetHistoryDB(ctx, filter)
+}
+
+// CreateIdempotencyKeyST user
+func (s *UsersStorage) CreateIdempotencyKeyST(ctx context.Context, rec structs.IdempotencyRecord, retention time.Duration, lease time.Duration) (structs.IdempotencyRecord, bool, error) {
+	return s.usersRepo.CreateIdempotencyKeyDB(ctx, rec, retention, lease)
+}
+
+// SaveIdempotentResponseST user
+func (s *UsersStorage) SaveIdempotentResponseST(ctx context.Context, login string, key string, statusCode int, response []byte) error {
+	return s.usersRepo.SaveIdempotentResponseDB(ctx, login, key, statusCode, response)
+}
+
+// DeleteIdempotencyKeyST user
+func (s *UsersStorage) DeleteIdempotencyKeyST(ctx context.Context, login string, key string) error {
+	return s.usersRepo.DeleteIdempotencyKeyDB(ctx, login, key)
+}
+
+// ReverseOperationST operation
+// Returns models.ErrOperationNotFound, models.ErrNotReversible, models.ErrAlreadyReversed, models.ErrUndoExpired,
+// models.ErrCoinsSpent, models.ErrInsufficientBalance or err
+func (s *UsersStorage) ReverseOperationST(ctx context.Context, reversal structs.Reversal) ([]structs.LedgerEntry, error) {
+	entries, err := s.usersRepo.ReverseOperationDB(ctx, reversal)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return nil, models.ErrOperationNotFound
+		}
+		if errors.Is(err, repository.ErrNotReversible) {
+			return nil, models.ErrNotReversible
+		}
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, models.ErrAlreadyReversed
+		}
+		if errors.Is(err, repository.ErrUndoExpired) {
+			return nil, models.ErrUndoExpired
+		}
+		if errors.Is(err, repository.ErrCoinsSpent) {
+			return nil, models.ErrCoinsSpent
+		}
+		if errors.Is(err, repository.ErrCheckConstraint) {
+			return nil, models.ErrInsufficientBalance
+		}
+		return nil, err
+	}
+	return entries, nil
+}
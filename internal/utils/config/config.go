@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -61,12 +62,150 @@ type Logger struct {
 	LogRate float64 `yaml:"rate"`
 }
 
+// Lockout - contains parameters of brute-force protection.
+// Zero MaxFailures disables lockout by that key.
+type Lockout struct {
+	LoginMaxFailures int           `yaml:"loginMaxFailures"`
+	IPMaxFailures    int           `yaml:"ipMaxFailures"`
+	BaseDelay        time.Duration `yaml:"baseDelay"`
+	MaxDelay         time.Duration `yaml:"maxDelay"`
+	FailureWindow    time.Duration `yaml:"failureWindow"`
+}
+
+// JWTKey - contains PEM files of JWT signing key.
+// Retired keys may have only public part, they are kept to verify not yet expired tokens.
+type JWTKey struct {
+	Kid            string `yaml:"kid"`
+	PrivateKeyPath string `yaml:"privateKey"`
+	PublicKeyPath  string `yaml:"publicKey"`
+}
+
+// JWT - contains parameters of access token signing.
+// Mode "hmac" signs with per session secret, "rs256" and "eddsa" sign with server keys.
+type JWT struct {
+	Mode      string   `yaml:"mode"`
+	Issuer    string   `yaml:"issuer"`
+	ActiveKid string   `yaml:"activeKid"`
+	Keys      []JWTKey `yaml:"keys"`
+}
+
+// SessionCache - contains parameters of in-process cache of session secrets.
+// Size 0 disables cache. TTL bounds how long session revoked by another instance stays usable here.
+type SessionCache struct {
+	Size          int           `yaml:"size"`
+	TTL           time.Duration `yaml:"ttl"`
+	TouchInterval time.Duration `yaml:"touchInterval"`
+}
+
+// TOTP - contains parameters of two-factor authentication.
+// Skew is number of 30 second steps accepted before and after current one.
+type TOTP struct {
+	Issuer string `yaml:"issuer"`
+	Skew   int    `yaml:"skew"`
+}
+
+// Credentials - contains login and password policy. Zero values mean defaults.
+// Empty LoginAllowedChars allows any letters and digits.
+// PasswordRequire lists character classes: digit, upper, lower, special.
+type Credentials struct {
+	LoginMinLength        int      `yaml:"loginMinLength"`
+	LoginMaxLength        int      `yaml:"loginMaxLength"`
+	LoginAllowedChars     string   `yaml:"loginAllowedChars"`
+	PasswordMinLength     int      `yaml:"passwordMinLength"`
+	PasswordMaxLength     int      `yaml:"passwordMaxLength"`
+	PasswordRequire       []string `yaml:"passwordRequire"`
+	BannedPasswordsFile   string   `yaml:"bannedPasswordsFile"`
+	ForbidLoginInPassword bool     `yaml:"forbidLoginInPassword"`
+}
+
+// PasswordHashing - contains parameters of password hashing.
+// Algorithm is "argon2id" (default) or "bcrypt". Argon2Memory is in KiB.
+// Hashes made by other algorithm or with other parameters are upgraded on successful login.
+type PasswordHashing struct {
+	Algorithm     string `yaml:"algorithm"`
+	BcryptCost    int    `yaml:"bcryptCost"`
+	Argon2Memory  uint32 `yaml:"argon2Memory"`
+	Argon2Time    uint32 `yaml:"argon2Time"`
+	Argon2Threads uint8  `yaml:"argon2Threads"`
+}
+
+// Auth - contains all parameters of authentication.
+type Auth struct {
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
+	// LegacyCombinedAuth keeps POST /api/auth creating accounts for unknown logins
+	LegacyCombinedAuth bool            `yaml:"legacyCombinedAuth"`
+	RequireInvite      bool            `yaml:"requireInvite"`
+	InviteTTL          time.Duration   `yaml:"inviteTTL"`
+	PasswordResetTTL   time.Duration   `yaml:"passwordResetTTL"`
+	Lockout            Lockout         `yaml:"lockout"`
+	JWT                JWT             `yaml:"jwt"`
+	SessionCache       SessionCache    `yaml:"sessionCache"`
+	TOTP               TOTP            `yaml:"totp"`
+	Credentials        Credentials     `yaml:"credentials"`
+	PasswordHashing    PasswordHashing `yaml:"passwordHashing"`
+}
+
+// Idempotency - contains parameters of Idempotency-Key handling.
+// Retention is how long result of request is kept for replay.
+// Lease is how long unfinished request holds its key, it must be longer than any request runs.
+type Idempotency struct {
+	Retention time.Duration `yaml:"retention"`
+	Lease     time.Duration `yaml:"lease"`
+}
+
+// Transfers - contains limits of sending coins. Zero value means no limit.
+// Daily and weekly limits are rolling: sum of coins sent during last 24 hours and 7 days.
+// UndoWindow is how long sender may undo transfer himself, zero disables undo.
+type Transfers struct {
+	MaxAmount              int           `yaml:"maxAmount"`
+	DailyLimit             int           `yaml:"dailyLimit"`
+	WeeklyLimit            int           `yaml:"weeklyLimit"`
+	PerRecipientDailyLimit int           `yaml:"perRecipientDailyLimit"`
+	UndoWindow             time.Duration `yaml:"undoWindow"`
+}
+
+// Coins - contains parameters of coin operations.
+type Coins struct {
+	Idempotency Idempotency `yaml:"idempotency"`
+	Transfers   Transfers   `yaml:"transfers"`
+}
+
+// Directory - contains parameters of user search.
+// Zero SearchPerMinute disables rate limit. Zero limits mean defaults, use SearchLimits to read them.
+type Directory struct {
+	DefaultLimit    int `yaml:"defaultLimit"`
+	MaxLimit        int `yaml:"maxLimit"`
+	SearchPerMinute int `yaml:"searchPerMinute"`
+	SearchBurst     int `yaml:"searchBurst"`
+}
+
+const (
+	defaultDirectoryLimit    = 20
+	defaultDirectoryMaxLimit = 100
+)
+
+// SearchLimits returns page size used when client doesn't set it and the biggest allowed one
+func (d Directory) SearchLimits() (int, int) {
+	limit, maxLimit := d.DefaultLimit, d.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = defaultDirectoryMaxLimit
+	}
+	if limit <= 0 {
+		limit = min(defaultDirectoryLimit, maxLimit)
+	}
+	return limit, maxLimit
+}
+
 type Config struct {
-	Project  Project  `yaml:"project"`
-	Rest     Rest     `yaml:"rest"`
-	Status   Status   `yaml:"status"`
-	Database Database `yaml:"database"`
-	Logger   Logger   `yaml:"logger"`
+	Project   Project   `yaml:"project"`
+	Rest      Rest      `yaml:"rest"`
+	Status    Status    `yaml:"status"`
+	Database  Database  `yaml:"database"`
+	Logger    Logger    `yaml:"logger"`
+	Auth      Auth      `yaml:"auth"`
+	Directory Directory `yaml:"directory"`
+	Coins     Coins     `yaml:"coins"`
 }
 
 func ReadConfigYAML() error {
@@ -0,0 +1,199 @@
+// Package credpolicy checks logins and passwords against rules from config.
+// Violations are returned as machine-readable reasons, so clients can explain what to fix.
+package credpolicy
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+)
+
+type Reason string
+
+const (
+	LoginTooShort         Reason = "login_too_short"
+	LoginTooLong          Reason = "login_too_long"
+	LoginBadChars         Reason = "login_bad_chars"
+	PasswordTooShort      Reason = "password_too_short"
+	PasswordTooLong       Reason = "password_too_long"
+	PasswordNeedsDigit    Reason = "password_needs_digit"
+	PasswordNeedsUpper    Reason = "password_needs_upper"
+	PasswordNeedsLower    Reason = "password_needs_lower"
+	PasswordNeedsSpecial  Reason = "password_needs_special"
+	PasswordBanned        Reason = "password_banned"
+	PasswordContainsLogin Reason = "password_contains_login"
+)
+
+// Character classes of config.Credentials.PasswordRequire
+const (
+	ClassDigit   = "digit"
+	ClassUpper   = "upper"
+	ClassLower   = "lower"
+	ClassSpecial = "special"
+)
+
+const (
+	defaultLoginMinLength    = 8
+	defaultLoginMaxLength    = 64
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 72 // больше bcrypt не учитывает
+)
+
+var defaultPasswordRequire = []string{ClassDigit, ClassUpper, ClassLower, ClassSpecial}
+
+type Policy struct {
+	loginMinLength    int
+	loginMaxLength    int
+	loginAllowedChars string
+	passwordMinLength int
+	passwordMaxLength int
+	passwordRequire   []string
+	forbidLogin       bool
+	banned            map[string]struct{}
+}
+
+// New builds policy from config and loads banned passwords file if it's set.
+// Zero values mean defaults, which match the rules the service always had.
+func New(cfg config.Credentials) (*Policy, error) {
+	p := &Policy{
+		loginMinLength:    orDefault(cfg.LoginMinLength, defaultLoginMinLength),
+		loginMaxLength:    orDefault(cfg.LoginMaxLength, defaultLoginMaxLength),
+		loginAllowedChars: cfg.LoginAllowedChars,
+		passwordMinLength: orDefault(cfg.PasswordMinLength, defaultPasswordMinLength),
+		passwordMaxLength: orDefault(cfg.PasswordMaxLength, defaultPasswordMaxLength),
+		passwordRequire:   cfg.PasswordRequire,
+		forbidLogin:       cfg.ForbidLoginInPassword,
+		banned:            map[string]struct{}{},
+	}
+	if p.passwordRequire == nil {
+		p.passwordRequire = defaultPasswordRequire
+	}
+
+	if cfg.BannedPasswordsFile != "" {
+		if err := p.loadBanned(cfg.BannedPasswordsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Default returns policy with default rules and no banned passwords
+func Default() *Policy {
+	p, _ := New(config.Credentials{})
+	return p
+}
+
+// CheckLogin returns reasons login breaks the policy, empty if it's fine
+func (p *Policy) CheckLogin(login string) []Reason {
+	reasons := []Reason{}
+
+	length := utf8.RuneCountInString(login)
+	if length < p.loginMinLength {
+		reasons = append(reasons, LoginTooShort)
+	}
+	if length > p.loginMaxLength {
+		reasons = append(reasons, LoginTooLong)
+	}
+	for _, c := range login {
+		if !p.isLoginChar(c) {
+			reasons = append(reasons, LoginBadChars)
+			break
+		}
+	}
+
+	return reasons
+}
+
+// CheckPassword returns reasons password breaks the policy, empty if it's fine.
+// Empty login skips "password must not contain login" rule.
+func (p *Policy) CheckPassword(login string, password string) []Reason {
+	reasons := []Reason{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.passwordMinLength {
+		reasons = append(reasons, PasswordTooShort)
+	}
+	if len(password) > p.passwordMaxLength {
+		reasons = append(reasons, PasswordTooLong)
+	}
+
+	has := map[string]bool{}
+	for _, c := range password {
+		switch {
+		case unicode.IsDigit(c):
+			has[ClassDigit] = true
+		case unicode.IsUpper(c):
+			has[ClassUpper] = true
+		case unicode.IsLower(c):
+			has[ClassLower] = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			has[ClassSpecial] = true
+		}
+	}
+	classReasons := map[string]Reason{
+		ClassDigit:   PasswordNeedsDigit,
+		ClassUpper:   PasswordNeedsUpper,
+		ClassLower:   PasswordNeedsLower,
+		ClassSpecial: PasswordNeedsSpecial,
+	}
+	for _, class := range []string{ClassDigit, ClassUpper, ClassLower, ClassSpecial} {
+		if slices.Contains(p.passwordRequire, class) && !has[class] {
+			reasons = append(reasons, classReasons[class])
+		}
+	}
+
+	if _, ok := p.banned[strings.ToLower(password)]; ok {
+		reasons = append(reasons, PasswordBanned)
+	}
+	if p.forbidLogin && login != "" && strings.Contains(strings.ToLower(password), strings.ToLower(login)) {
+		reasons = append(reasons, PasswordContainsLogin)
+	}
+
+	return reasons
+}
+
+// Check returns reasons of both login and password
+func (p *Policy) Check(login string, password string) []Reason {
+	return append(p.CheckLogin(login), p.CheckPassword(login, password)...)
+}
+
+func (p *Policy) isLoginChar(c rune) bool {
+	if p.loginAllowedChars == "" {
+		return unicode.IsDigit(c) || unicode.IsLetter(c)
+	}
+	return strings.ContainsRune(p.loginAllowedChars, c)
+}
+
+// loadBanned reads one password per line, empty lines and lines starting with # are skipped
+func (p *Policy) loadBanned(path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+func orDefault(v int, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
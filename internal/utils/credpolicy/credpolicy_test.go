@@ -0,0 +1,102 @@
+package credpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Default(t *testing.T) {
+	p := Default()
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		want     []Reason
+	}{
+		{
+			name:     "Valid",
+			login:    "user1user1",
+			password: "Lhjxb[eq1",
+			want:     []Reason{},
+		},
+		{
+			name:     "Short login with bad chars",
+			login:    "us-er",
+			password: "Lhjxb[eq1",
+			want:     []Reason{LoginTooShort, LoginBadChars},
+		},
+		{
+			name:     "Cyrillic login",
+			login:    "пользователь1",
+			password: "Lhjxb[eq1",
+			want:     []Reason{},
+		},
+		{
+			name:     "Weak password",
+			login:    "user1user1",
+			password: "password",
+			want:     []Reason{PasswordNeedsDigit, PasswordNeedsUpper, PasswordNeedsSpecial},
+		},
+		{
+			name:     "Short password",
+			login:    "user1user1",
+			password: "Aa1!",
+			want:     []Reason{PasswordTooShort},
+		},
+		{
+			name:     "Too long password",
+			login:    "user1user1",
+			password: "Aa1!" + string(make([]byte, 70)),
+			want:     []Reason{PasswordTooLong},
+		},
+		{
+			name:     "Login in password allowed by default",
+			login:    "user1user1",
+			password: "User1user1!",
+			want:     []Reason{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Check(tt.login, tt.password))
+		})
+	}
+}
+
+func TestPolicy_Configured(t *testing.T) {
+	banned := filepath.Join(t.TempDir(), "banned.txt")
+	err := os.WriteFile(banned, []byte("# common\n\nP@ssw0rd1\n"), 0o600)
+	require.NoError(t, err)
+
+	p, err := New(config.Credentials{
+		LoginMinLength:        3,
+		LoginMaxLength:        10,
+		LoginAllowedChars:     "abcdefghijklmnopqrstuvwxyz0123456789.",
+		PasswordMinLength:     10,
+		PasswordRequire:       []string{ClassDigit},
+		BannedPasswordsFile:   banned,
+		ForbidLoginInPassword: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Reason{}, p.CheckLogin("ivan.ivanov"[:10]))
+	assert.Equal(t, []Reason{LoginTooLong}, p.CheckLogin("ivan.ivanov"))
+	assert.Equal(t, []Reason{LoginBadChars}, p.CheckLogin("Ivan"))
+
+	assert.Equal(t, []Reason{}, p.CheckPassword("ivan", "longpassword1"))
+	assert.Equal(t, []Reason{PasswordNeedsDigit}, p.CheckPassword("ivan", "longpassword"))
+	assert.Equal(t, []Reason{PasswordTooShort, PasswordBanned}, p.CheckPassword("ivan", "p@ssw0rd1"))
+	assert.Equal(t, []Reason{PasswordContainsLogin}, p.CheckPassword("ivan", "IVAN-secret-1"))
+	assert.Equal(t, []Reason{}, p.CheckPassword("", "IVAN-secret-1"))
+}
+
+func TestNew_MissingBannedFile(t *testing.T) {
+	_, err := New(config.Credentials{BannedPasswordsFile: filepath.Join(t.TempDir(), "none.txt")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ModeHMAC  = "hmac"
+	ModeRS256 = "rs256"
+	ModeEdDSA = "eddsa"
+)
+
+var ErrUnknownKid = errors.New("unknown key id")
+
+// KeySet holds server keys of asymmetric JWT mode.
+// Active key signs new tokens, every key (retired ones included) verifies.
+type KeySet struct {
+	method     jwt.SigningMethod
+	activeKid  string
+	privateKey crypto.Signer
+	publicKeys map[string]crypto.PublicKey
+	kids       []string
+}
+
+// JWK - public key in RFC 7517 format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Load reads PEM keys from config. Returns nil KeySet in HMAC mode.
+func Load(cfg config.JWT) (*KeySet, error) {
+	mode := strings.ToLower(cfg.Mode)
+
+	ks := &KeySet{
+		activeKid:  cfg.ActiveKid,
+		publicKeys: make(map[string]crypto.PublicKey, len(cfg.Keys)),
+	}
+
+	switch mode {
+	case "", ModeHMAC:
+		return nil, nil
+	case ModeRS256:
+		ks.method = jwt.SigningMethodRS256
+	case ModeEdDSA:
+		ks.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unknown jwt mode %q", cfg.Mode)
+	}
+
+	for _, key := range cfg.Keys {
+		if key.Kid == "" {
+			return nil, errors.New("jwt key without kid")
+		}
+		if _, ok := ks.publicKeys[key.Kid]; ok {
+			return nil, fmt.Errorf("duplicate jwt kid %q", key.Kid)
+		}
+
+		var pub crypto.PublicKey
+		switch {
+		case key.PrivateKeyPath != "":
+			priv, err := readPrivateKey(key.PrivateKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: %w", key.Kid, err)
+			}
+			if key.Kid == cfg.ActiveKid {
+				ks.privateKey = priv
+			}
+			pub = priv.Public()
+		case key.PublicKeyPath != "":
+			var err error
+			pub, err = readPublicKey(key.PublicKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: %w", key.Kid, err)
+			}
+		default:
+			return nil, fmt.Errorf("jwt key %q has neither private nor public key", key.Kid)
+		}
+
+		if !ks.matchesMethod(pub) {
+			return nil, fmt.Errorf("jwt key %q doesn't match mode %s", key.Kid, mode)
+		}
+
+		ks.publicKeys[key.Kid] = pub
+		ks.kids = append(ks.kids, key.Kid)
+	}
+
+	if ks.privateKey == nil {
+		return nil, fmt.Errorf("no private key for active kid %q", cfg.ActiveKid)
+	}
+
+	return ks, nil
+}
+
+// Sign signs claims with the active key and puts its kid into header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	token.Header["kid"] = ks.activeKid
+
+	return token.SignedString(ks.privateKey)
+}
+
+// Key returns public key for verification of token by its kid
+func (ks *KeySet) Key(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != ks.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	pub, ok := ks.publicKeys[kid]
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+
+	return pub, nil
+}
+
+// JWKS returns every public key of set, nil set gives empty JWKS
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if ks == nil {
+		return jwks
+	}
+
+	for _, kid := range ks.kids {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: ks.method.Alg()}
+
+		switch pub := ks.publicKeys[kid].(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func (ks *KeySet) matchesMethod(pub crypto.PublicKey) bool {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return ks.method == jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		return ks.method == jwt.SigningMethodEdDSA
+	default:
+		return false
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
@@ -0,0 +1,191 @@
+package keyset
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name string, typ string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func writePrivateKey(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return writePEM(t, name, "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return writePEM(t, name, "PUBLIC KEY", der)
+}
+
+func TestLoad_HMAC(t *testing.T) {
+	ks, err := Load(config.JWT{Mode: ModeHMAC})
+	require.NoError(t, err)
+	assert.Nil(t, ks)
+	assert.Empty(t, ks.JWKS().Keys)
+}
+
+func TestKeySet_RS256Rotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	oldCfg := config.JWT{
+		Mode:      ModeRS256,
+		ActiveKid: "old",
+		Keys:      []config.JWTKey{{Kid: "old", PrivateKeyPath: writePrivateKey(t, "old.pem", oldKey)}},
+	}
+	oldKS, err := Load(oldCfg)
+	require.NoError(t, err)
+
+	oldToken, err := oldKS.Sign(jwt.MapClaims{"sub": "user1"})
+	require.NoError(t, err)
+
+	ks, err := Load(config.JWT{
+		Mode:      ModeRS256,
+		ActiveKid: "new",
+		Keys: []config.JWTKey{
+			{Kid: "new", PrivateKeyPath: writePrivateKey(t, "new.pem", newKey)},
+			{Kid: "old", PublicKeyPath: writePublicKey(t, "old.pub.pem", &oldKey.PublicKey)},
+		},
+	})
+	require.NoError(t, err)
+
+	newToken, err := ks.Sign(jwt.MapClaims{"sub": "user1"})
+	require.NoError(t, err)
+
+	for _, tokStr := range []string{oldToken, newToken} {
+		token, err := jwt.Parse(tokStr, ks.Key)
+		require.NoError(t, err)
+		assert.True(t, token.Valid)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", token.Header["kid"])
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "RS256", jwks.Keys[0].Alg)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+	assert.Equal(t, "old", jwks.Keys[1].Kid)
+}
+
+func TestKeySet_EdDSA(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks, err := Load(config.JWT{
+		Mode:      ModeEdDSA,
+		ActiveKid: "k1",
+		Keys:      []config.JWTKey{{Kid: "k1", PrivateKeyPath: writePrivateKey(t, "k1.pem", priv)}},
+	})
+	require.NoError(t, err)
+
+	tokStr, err := ks.Sign(jwt.MapClaims{"sub": "user1"})
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(tokStr, ks.Key)
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+	assert.Len(t, jwks.Keys[0].X, 43) // 32 байта в base64url без паддинга
+}
+
+func TestKeySet_Key_Rejects(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks, err := Load(config.JWT{
+		Mode:      ModeEdDSA,
+		ActiveKid: "k1",
+		Keys:      []config.JWTKey{{Kid: "k1", PrivateKeyPath: writePrivateKey(t, "k1.pem", priv)}},
+	})
+	require.NoError(t, err)
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user1"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = jwt.Parse(hmacToken, ks.Key)
+	assert.Error(t, err)
+
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "user1"})
+	unknownKid.Header["kid"] = "k2"
+	tokStr, err := unknownKid.SignedString(priv)
+	require.NoError(t, err)
+	_, err = jwt.Parse(tokStr, ks.Key)
+	assert.ErrorIs(t, err, ErrUnknownKid)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := writePrivateKey(t, "rsa.pem", rsaKey)
+
+	tests := []struct {
+		name string
+		cfg  config.JWT
+	}{
+		{
+			name: "Unknown mode",
+			cfg:  config.JWT{Mode: "hs512"},
+		},
+		{
+			name: "Key doesn't match mode",
+			cfg: config.JWT{Mode: ModeEdDSA, ActiveKid: "k1",
+				Keys: []config.JWTKey{{Kid: "k1", PrivateKeyPath: rsaPath}}},
+		},
+		{
+			name: "No active private key",
+			cfg: config.JWT{Mode: ModeRS256, ActiveKid: "k2",
+				Keys: []config.JWTKey{{Kid: "k1", PrivateKeyPath: rsaPath}}},
+		},
+		{
+			name: "Duplicate kid",
+			cfg: config.JWT{Mode: ModeRS256, ActiveKid: "k1",
+				Keys: []config.JWTKey{{Kid: "k1", PrivateKeyPath: rsaPath}, {Kid: "k1", PrivateKeyPath: rsaPath}}},
+		},
+		{
+			name: "Missing file",
+			cfg: config.JWT{Mode: ModeRS256, ActiveKid: "k1",
+				Keys: []config.JWTKey{{Kid: "k1", PrivateKeyPath: filepath.Join(t.TempDir(), "none.pem")}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package passhash hashes passwords in Go with bcrypt or argon2id.
+// Hashes of either algorithm are verified, so stored hashes can be upgraded one login at a time.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+)
+
+// Значения по умолчанию из рекомендаций OWASP
+const (
+	defaultBcryptCost    = 10
+	defaultArgon2Memory  = 19 * 1024
+	defaultArgon2Time    = 2
+	defaultArgon2Threads = 1
+	argon2SaltLength     = 16
+	argon2KeyLength      = 32
+)
+
+// Пределы параметров argon2id: хэш из БД с большими m и t не должен съедать память и CPU
+const (
+	maxArgon2Memory = 1024 * 1024 // 1 GiB
+	maxArgon2Time   = 16
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash of any supported algorithm
+	Verify(hash string, password string) (bool, error)
+	// NeedsRehash reports whether hash differs from configured algorithm or parameters
+	NeedsRehash(hash string) bool
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// validate checks that params are usable: argon2.IDKey panics on zero threads
+func (p argon2Params) validate() error {
+	if p.threads == 0 || p.time == 0 || p.time > maxArgon2Time ||
+		p.memory < 8*uint32(p.threads) || p.memory > maxArgon2Memory {
+		return fmt.Errorf("argon2 params must have t in [1, %d], p > 0 and m in [8*p, %d]", maxArgon2Time, maxArgon2Memory)
+	}
+	return nil
+}
+
+type hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}
+
+// New creates hasher from config. Zero values mean defaults, empty algorithm means argon2id.
+func New(cfg config.PasswordHashing) (Hasher, error) {
+	h := &hasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2: argon2Params{
+			memory:  cfg.Argon2Memory,
+			time:    cfg.Argon2Time,
+			threads: cfg.Argon2Threads,
+		},
+	}
+	if h.algorithm == "" {
+		h.algorithm = AlgArgon2id
+	}
+	if h.bcryptCost == 0 {
+		h.bcryptCost = defaultBcryptCost
+	}
+	if h.argon2.memory == 0 {
+		h.argon2.memory = defaultArgon2Memory
+	}
+	if h.argon2.time == 0 {
+		h.argon2.time = defaultArgon2Time
+	}
+	if h.argon2.threads == 0 {
+		h.argon2.threads = defaultArgon2Threads
+	}
+
+	switch h.algorithm {
+	case AlgBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be in [%d, %d]", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgArgon2id:
+		if err := h.argon2.validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", h.algorithm)
+	}
+
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return encodeArgon2(h.argon2, salt, argon2.IDKey([]byte(password), salt,
+		h.argon2.time, h.argon2.memory, h.argon2.threads, argon2KeyLength)), nil
+}
+
+func (h *hasher) Verify(hash string, password string) (bool, error) {
+	switch {
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	if h.algorithm == AlgBcrypt {
+		if !isBcrypt(hash) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	params, _, _, err := decodeArgon2(hash)
+	return err != nil || params != h.argon2
+}
+
+// isBcrypt matches hashes of Go bcrypt and of pgcrypto crypt(..., gen_salt('bf'))
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// encodeArgon2 uses PHC string format: $argon2id$v=19$m=...,t=...,p=...$salt$key
+func encodeArgon2(p argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgArgon2id {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	p := argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil || p.validate() != nil {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	return p, salt, key, nil
+}
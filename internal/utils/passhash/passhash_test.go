@@ -0,0 +1,141 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Avito/internal/utils/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Маленькие параметры, чтобы тесты шли быстро
+var (
+	testArgon2 = config.PasswordHashing{Algorithm: AlgArgon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}
+	testBcrypt = config.PasswordHashing{Algorithm: AlgBcrypt, BcryptCost: bcrypt.MinCost}
+)
+
+func TestHasher_HashVerify(t *testing.T) {
+	for _, cfg := range []config.PasswordHashing{testArgon2, testBcrypt} {
+		t.Run(cfg.Algorithm, func(t *testing.T) {
+			h, err := New(cfg)
+			require.NoError(t, err)
+
+			hash, err := h.Hash("Lhjxb[eq1")
+			require.NoError(t, err)
+
+			ok, err := h.Verify(hash, "Lhjxb[eq1")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = h.Verify(hash, "Lhjxb[eq2")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, h.NeedsRehash(hash))
+
+			other, err := h.Hash("Lhjxb[eq1")
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, other, "salt must differ")
+		})
+	}
+}
+
+func TestHasher_Argon2Format(t *testing.T) {
+	h, err := New(testArgon2)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("Lhjxb[eq1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+}
+
+func TestHasher_LegacyBcrypt(t *testing.T) {
+	// Такой же формат даёт pgcrypto crypt($1, gen_salt('bf'))
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Lhjxb[eq1"), 6)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(legacy), "$2a$06$"))
+
+	h, err := New(testArgon2)
+	require.NoError(t, err)
+
+	ok, err := h.Verify(string(legacy), "Lhjxb[eq1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(string(legacy)))
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	argon, err := New(testArgon2)
+	require.NoError(t, err)
+	argonHash, err := argon.Hash("Lhjxb[eq1")
+	require.NoError(t, err)
+
+	stronger := testArgon2
+	stronger.Argon2Time = 2
+	strongerArgon, err := New(stronger)
+	require.NoError(t, err)
+	assert.True(t, strongerArgon.NeedsRehash(argonHash))
+
+	bc, err := New(testBcrypt)
+	require.NoError(t, err)
+	assert.True(t, bc.NeedsRehash(argonHash))
+
+	bcHash, err := bc.Hash("Lhjxb[eq1")
+	require.NoError(t, err)
+	strongerBcrypt := testBcrypt
+	strongerBcrypt.BcryptCost = bcrypt.MinCost + 1
+	bc2, err := New(strongerBcrypt)
+	require.NoError(t, err)
+	assert.True(t, bc2.NeedsRehash(bcHash))
+}
+
+func TestHasher_Errors(t *testing.T) {
+	_, err := New(config.PasswordHashing{Algorithm: "md5"})
+	assert.Error(t, err)
+
+	_, err = New(config.PasswordHashing{Algorithm: AlgBcrypt, BcryptCost: 50})
+	assert.Error(t, err)
+
+	h, err := New(testArgon2)
+	require.NoError(t, err)
+
+	_, err = h.Verify("plain-text", "plain-text")
+	assert.ErrorIs(t, err, ErrUnknownHash)
+	_, err = h.Verify("$argon2id$v=19$m=64,t=1,p=1$bad", "x")
+	assert.ErrorIs(t, err, ErrUnknownHash)
+
+	_, err = New(config.PasswordHashing{Argon2Memory: maxArgon2Memory + 1})
+	assert.Error(t, err)
+}
+
+func TestHasher_BadArgon2Params(t *testing.T) {
+	h, err := New(testArgon2)
+	require.NoError(t, err)
+	hash, err := h.Hash("Lhjxb[eq1")
+	require.NoError(t, err)
+	parts := strings.Split(hash, "$")
+
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"zero threads", "m=64,t=1,p=0"},
+		{"zero time", "m=64,t=0,p=1"},
+		{"huge time", "m=64,t=4294967295,p=1"},
+		{"huge memory", "m=4294967295,t=1,p=1"},
+		{"memory below 8*p", "m=7,t=1,p=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts[3] = tt.params
+			bad := strings.Join(parts, "$")
+
+			ok, err := h.Verify(bad, "Lhjxb[eq1")
+			assert.False(t, ok)
+			assert.ErrorIs(t, err, ErrUnknownHash)
+			assert.True(t, h.NeedsRehash(bad))
+		})
+	}
+}